@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/email"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/outbox"
+	"sync"
+)
+
+var (
+	mu      sync.Mutex
+	current *Handler
+)
+
+func buildConfig(ctx context.Context) (*application.Config, error) {
+	loader := application.Loader{
+		File:    ".env",
+		Require: []application.Section{application.SectionPostgres, application.SectionEmail},
+	}
+	return loader.Load(ctx)
+}
+
+func session(configs *application.Config) (*Handler, error) {
+	transport, err := email.NewTransport(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	pg := configs.PgConfig
+	database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+
+	return NewHandler(outbox.NewDispatcher(outbox.NewRepository(database), transport, outbox.Config(configs.Outbox))), nil
+}
+
+// config builds the handler on the first invocation and reuses it while the lambda stays warm,
+// a failed build is retried by the next invocation.
+func config(ctx context.Context) (*Handler, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current != nil {
+		return current, nil
+	}
+
+	configs, err := buildConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := logging.Setup(logging.Config(configs.Logging)); err != nil {
+		return nil, err
+	}
+
+	h, err := session(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	current = h
+	return h, nil
+}
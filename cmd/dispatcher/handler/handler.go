@@ -0,0 +1,59 @@
+// Package handler is the lambda that sends the emails queued in the email outbox, it is invoked by a
+// schedule (e.g. an EventBridge rule with rate(1 minute)).
+package handler
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	log "github.com/sirupsen/logrus"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/outbox"
+)
+
+//go:generate mockgen -source=handler.go -destination=handler_mock.go -package=handler
+
+type dispatcher interface {
+	Dispatch(ctx context.Context) (outbox.Result, error)
+}
+
+type Handler struct {
+	dispatcher dispatcher
+}
+
+func NewHandler(dispatcher dispatcher) *Handler {
+	return &Handler{dispatcher: dispatcher}
+}
+
+func ScheduledEvent(ctx context.Context, event events.CloudWatchEvent) (outbox.Result, error) {
+	if invocation, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = logging.WithRequestID(ctx, invocation.AwsRequestID)
+	}
+
+	h, err := config(ctx)
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "dispatch_email"}).
+			Error(err)
+		return outbox.Result{}, err
+	}
+
+	return h.Dispatch(ctx)
+}
+
+// Dispatch sends the due emails once, the emails left when the invocation times out are sent by the next
+// one. Failed deliveries are rescheduled by the dispatcher, only the errors of the outbox fail the invocation.
+func (h *Handler) Dispatch(ctx context.Context) (outbox.Result, error) {
+	result, err := h.dispatcher.Dispatch(ctx)
+	if err != nil && ctx.Err() == nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "dispatch_email"}).
+			Errorf("dispatch failed: %s", err.Error())
+		return result, err
+	}
+
+	log.WithContext(ctx).
+		WithFields(log.Fields{"event": "dispatch_email"}).
+		Infof("sent %d, retried %d, dead-lettered %d", result.Sent, result.Retried, result.Dead)
+	return result, nil
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: handler.go
+
+// Package handler is a generated GoMock package.
+package handler
+
+import (
+	context "context"
+	reflect "reflect"
+	outbox "stori-challenge/internal/outbox"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockdispatcher is a mock of dispatcher interface.
+type Mockdispatcher struct {
+	ctrl     *gomock.Controller
+	recorder *MockdispatcherMockRecorder
+}
+
+// MockdispatcherMockRecorder is the mock recorder for Mockdispatcher.
+type MockdispatcherMockRecorder struct {
+	mock *Mockdispatcher
+}
+
+// NewMockdispatcher creates a new mock instance.
+func NewMockdispatcher(ctrl *gomock.Controller) *Mockdispatcher {
+	mock := &Mockdispatcher{ctrl: ctrl}
+	mock.recorder = &MockdispatcherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockdispatcher) EXPECT() *MockdispatcherMockRecorder {
+	return m.recorder
+}
+
+// Dispatch mocks base method.
+func (m *Mockdispatcher) Dispatch(ctx context.Context) (outbox.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dispatch", ctx)
+	ret0, _ := ret[0].(outbox.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dispatch indicates an expected call of Dispatch.
+func (mr *MockdispatcherMockRecorder) Dispatch(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dispatch", reflect.TypeOf((*Mockdispatcher)(nil).Dispatch), ctx)
+}
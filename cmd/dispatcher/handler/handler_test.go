@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"stori-challenge/internal/outbox"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		result outbox.Result
+		err    error
+	}{
+		{name: "sent", result: outbox.Result{Sent: 2, Retried: 1}},
+		{name: "outbox_unavailable", result: outbox.Result{Sent: 1}, err: errors.New("connection refused")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dispatcher := NewMockdispatcher(ctrl)
+			dispatcher.EXPECT().Dispatch(gomock.Any()).Return(tc.result, tc.err)
+
+			result, err := NewHandler(dispatcher).Dispatch(context.Background())
+
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.result, result)
+		})
+	}
+}
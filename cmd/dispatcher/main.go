@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"stori-challenge/cmd/dispatcher/handler"
+)
+
+func main() {
+	lambda.Start(handler.ScheduledEvent)
+}
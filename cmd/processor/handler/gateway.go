@@ -1,44 +1,82 @@
 package handler
 
 import (
-	"github.com/joho/godotenv"
-	"os"
+	"context"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/application"
 	"stori-challenge/internal/email"
 	"stori-challenge/internal/integrations/aws/s3"
-	"stori-challenge/internal/integrations/aws/ses"
 	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/outbox"
 	"stori-challenge/internal/transaction"
+	"stori-challenge/pg_migrations"
+	"sync"
 )
 
-func buildConfig() (map[string]string, error) {
-	path, err := os.Getwd()
-	if err != nil {
-		return nil, err
+var (
+	mu      sync.Mutex
+	current *Handler
+)
+
+func buildConfig(ctx context.Context) (*application.Config, error) {
+	loader := application.Loader{
+		File:    ".env",
+		Require: []application.Section{application.SectionPostgres, application.SectionEmail, application.SectionStorage},
 	}
-	config, err := godotenv.Read(path + "/.env")
+	return loader.Load(ctx)
+}
+
+func session(ctx context.Context, configs *application.Config) (*Handler, error) {
+	s3Service, err := s3.NewS3Service(configs.S3Config)
 	if err != nil {
 		return nil, err
 	}
-	return config, nil
-}
 
-func session(configs map[string]string) *Handler {
-	db := db.InitPostgres(configs["host"], configs["database"], configs["user"], configs["password"])
-	repository := transaction.NewRepository(db)
-	emailService := email.NewService(ses.NewService(configs))
-	s3Service := s3.NewS3Service(configs)
+	pg := configs.PgConfig
+	database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+	if pg.AutoMigrate {
+		if err := db.MigrateUp(ctx, database, pgmigrations.FS); err != nil {
+			database.Close()
+			return nil, err
+		}
+	}
 
-	return NewHandler(transaction.NewService(emailService, s3Service, repository))
+	repository := transaction.NewRepository(database, transaction.InsertConfig{
+		Strategy:  transaction.InsertStrategy(pg.InsertStrategy),
+		BatchSize: pg.InsertBatchSize,
+	})
+	accounts := account.NewRepository(database)
+	// the emails are queued with the transactions of the file, the dispatcher lambda sends them
+	emailService := email.NewService(outbox.NewRepository(database), configs.EmailConfig.From, configs.EmailConfig.Unsubscribe, configs.EmailConfig.Locale)
+
+	return NewHandler(transaction.NewService(emailService, s3Service, repository, db.NewUnitOfWork(database), accounts,
+		transaction.Config(configs.Processing))), nil
 }
 
-func config() *Handler {
-	credentials, err := buildConfig()
+// config builds the handler on the first invocation and reuses it while the lambda stays warm,
+// a failed build is retried by the next invocation.
+func config(ctx context.Context) (*Handler, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current != nil {
+		return current, nil
+	}
+
+	configs, err := buildConfig(ctx)
 	if err != nil {
-		if err != nil {
-			panic(err)
-		}
+		return nil, err
+	}
+	if err := logging.Setup(logging.Config(configs.Logging)); err != nil {
+		return nil, err
+	}
+
+	h, err := session(ctx, configs)
+	if err != nil {
+		return nil, err
 	}
 
-	h := session(credentials)
-	return h
+	current = h
+	return h, nil
 }
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/logging"
 	"stori-challenge/internal/model"
+	"stori-challenge/internal/transaction"
 )
 
 //go:generate mockgen -source=handler.go -destination=handler_mock.go -package=handler
@@ -16,7 +20,7 @@ type Handler struct {
 }
 
 type service interface {
-	ProcessCsv(context.Context) (*model.Summary, error)
+	ProcessCsv(ctx context.Context, bucket, key string) (*model.Summary, error)
 }
 
 func NewHandler(service service) *Handler {
@@ -25,33 +29,70 @@ func NewHandler(service service) *Handler {
 	}
 }
 
-func ProxyLambdaEvent() (events.APIGatewayProxyResponse, error) {
-	return config().LambdaEvent()
+// errorBody is the body of a failed response, the cause of the error is only logged.
+type errorBody struct {
+	Code  apperr.Code `json:"code"`
+	Error string      `json:"error"`
 }
 
-func (h *Handler) LambdaEvent() (events.APIGatewayProxyResponse, error) {
-	ctx := context.Background()
+func errorResponse(err error) events.APIGatewayProxyResponse {
+	body, _ := json.Marshal(errorBody{Code: apperr.CodeOf(err), Error: apperr.MessageOf(err)})
+	return events.APIGatewayProxyResponse{
+		StatusCode: apperr.StatusOf(err),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}
+}
 
-	summary, err := h.service.ProcessCsv(ctx)
+func ProxyLambdaEvent(ctx context.Context, event events.S3Event) (events.APIGatewayProxyResponse, error) {
+	if invocation, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = logging.WithRequestID(ctx, invocation.AwsRequestID)
+	}
+
+	h, err := config(ctx)
 	if err != nil {
 		log.WithContext(ctx).
 			WithFields(log.Fields{"event": "lambda_event"}).
 			Error(err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       err.Error(),
-		}, err
+		return errorResponse(err), err
+	}
+
+	return h.LambdaEvent(ctx, event)
+}
+
+// LambdaEvent processes every object referenced by the s3 event, each uploaded file
+// produces its own summary.
+func (h *Handler) LambdaEvent(ctx context.Context, event events.S3Event) (events.APIGatewayProxyResponse, error) {
+	summaries := make([]*model.Summary, 0, len(event.Records))
+
+	for _, record := range event.Records {
+		bucket := record.S3.Bucket.Name
+		key := record.S3.Object.URLDecodedKey
+		if key == "" {
+			key = record.S3.Object.Key
+		}
+
+		// error reports are uploaded to the same bucket, they must not trigger a new statement
+		if transaction.IsErrorReport(key) {
+			continue
+		}
+
+		summary, err := h.service.ProcessCsv(ctx, bucket, key)
+		if err != nil {
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "lambda_event", "bucket": bucket, "key": key}).
+				Error(err)
+			return errorResponse(err), err
+		}
+		summaries = append(summaries, summary)
 	}
 
-	body, err := json.Marshal(summary)
+	body, err := json.Marshal(summaries)
 	if err != nil {
 		log.WithContext(ctx).
 			WithFields(log.Fields{"event": "lambda_event"}).
 			Error(err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       err.Error(),
-		}, err
+		return errorResponse(err), err
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -36,16 +36,16 @@ func (m *Mockservice) EXPECT() *MockserviceMockRecorder {
 }
 
 // ProcessCsv mocks base method.
-func (m *Mockservice) ProcessCsv(arg0 context.Context) (*model.Summary, error) {
+func (m *Mockservice) ProcessCsv(ctx context.Context, bucket, key string) (*model.Summary, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessCsv", arg0)
+	ret := m.ctrl.Call(m, "ProcessCsv", ctx, bucket, key)
 	ret0, _ := ret[0].(*model.Summary)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ProcessCsv indicates an expected call of ProcessCsv.
-func (mr *MockserviceMockRecorder) ProcessCsv(arg0 interface{}) *gomock.Call {
+func (mr *MockserviceMockRecorder) ProcessCsv(ctx, bucket, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessCsv", reflect.TypeOf((*Mockservice)(nil).ProcessCsv), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessCsv", reflect.TypeOf((*Mockservice)(nil).ProcessCsv), ctx, bucket, key)
 }
@@ -1,14 +1,27 @@
 package handler
 
 import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/apperr"
 	"stori-challenge/internal/model"
 	"testing"
 )
 
+func s3Record(bucket, key string) events.S3EventRecord {
+	return events.S3EventRecord{
+		S3: events.S3Entity{
+			Bucket: events.S3Bucket{Name: bucket},
+			Object: events.S3Object{Key: key, URLDecodedKey: key},
+		},
+	}
+}
+
 func TestLambdaEvent(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -19,13 +32,25 @@ func TestLambdaEvent(t *testing.T) {
 	type fields struct {
 		endingBalance float64
 		summary       *model.Summary
+		event         events.S3Event
 	}
 
 	type want struct {
 		statusCode int
 		err        error
+		body       string
 	}
 
+	failure := func(err error) func(fields fields) {
+		return func(fields fields) {
+			handler.service.(*Mockservice).
+				EXPECT().
+				ProcessCsv(gomock.Any(), "storicsv", "transactions.csv").
+				Return(nil, err)
+		}
+	}
+	event := events.S3Event{Records: []events.S3EventRecord{s3Record("storicsv", "transactions.csv")}}
+
 	tests := []struct {
 		name         string
 		fields       fields
@@ -36,11 +61,35 @@ func TestLambdaEvent(t *testing.T) {
 			name: "ok",
 			fields: fields{
 				endingBalance: 10,
+				event:         events.S3Event{Records: []events.S3EventRecord{s3Record("storicsv", "transactions.csv")}},
+			},
+			expectations: func(fields fields) {
+				handler.service.(*Mockservice).
+					EXPECT().
+					ProcessCsv(gomock.Any(), "storicsv", "transactions.csv").
+					Return(fields.summary, nil)
+			},
+			want: want{
+				statusCode: http.StatusOK,
+				err:        nil,
+			},
+		},
+		{
+			name: "ok_multiple_records",
+			fields: fields{
+				event: events.S3Event{Records: []events.S3EventRecord{
+					s3Record("storicsv", "1/2024-01-01.csv"),
+					s3Record("storicsv", "2/2024-01-01.csv"),
+				}},
 			},
 			expectations: func(fields fields) {
 				handler.service.(*Mockservice).
 					EXPECT().
-					ProcessCsv(gomock.Any()).
+					ProcessCsv(gomock.Any(), "storicsv", "1/2024-01-01.csv").
+					Return(fields.summary, nil)
+				handler.service.(*Mockservice).
+					EXPECT().
+					ProcessCsv(gomock.Any(), "storicsv", "2/2024-01-01.csv").
 					Return(fields.summary, nil)
 			},
 			want: want{
@@ -48,31 +97,107 @@ func TestLambdaEvent(t *testing.T) {
 				err:        nil,
 			},
 		},
+		{
+			name: "skip_error_report",
+			fields: fields{
+				event: events.S3Event{Records: []events.S3EventRecord{s3Record("storicsv", "42/transactions.errors.csv")}},
+			},
+			expectations: func(fields fields) {},
+			want: want{
+				statusCode: http.StatusOK,
+				err:        nil,
+			},
+		},
 		{
 			name: "fail",
 			fields: fields{
 				endingBalance: 0,
+				event:         events.S3Event{Records: []events.S3EventRecord{s3Record("storicsv", "transactions.csv")}},
 			},
 			expectations: func(fields fields) {
 				handler.service.(*Mockservice).
 					EXPECT().
-					ProcessCsv(gomock.Any()).
+					ProcessCsv(gomock.Any(), "storicsv", "transactions.csv").
 					Return(fields.summary, errors.New("fail"))
 			},
 			want: want{
 				statusCode: http.StatusInternalServerError,
 				err:        errors.New("fail"),
+				body:       `{"code":"internal","error":"internal error"}`,
+			},
+		},
+		{
+			name:         "invalid_row",
+			fields:       fields{event: event},
+			expectations: failure(apperr.InvalidRow(errors.New("line 3: invalid amount"))),
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+				err:        errors.New("line 3: invalid amount"),
+				body:       `{"code":"invalid_row","error":"line 3: invalid amount"}`,
+			},
+		},
+		{
+			name:         "unknown_account",
+			fields:       fields{event: event},
+			expectations: failure(apperr.Wrap(apperr.ErrNotFound, errors.Wrap(account.ErrNotFound, "account 42"))),
+			want: want{
+				statusCode: http.StatusNotFound,
+				err:        errors.New("account 42: account not found"),
+				body:       `{"code":"not_found","error":"the requested resource does not exist"}`,
+			},
+		},
+		{
+			name:         "unresolved_account",
+			fields:       fields{event: event},
+			expectations: failure(apperr.Wrap(apperr.ErrInvalidRequest, errors.Wrap(account.ErrUnresolved, "key transactions.csv"))),
+			want: want{
+				statusCode: http.StatusUnprocessableEntity,
+				err:        errors.New("key transactions.csv: account id could not be resolved"),
+				body:       `{"code":"invalid_request","error":"the request is invalid"}`,
+			},
+		},
+		{
+			name:         "source_unavailable",
+			fields:       fields{event: event},
+			expectations: failure(apperr.Wrap(apperr.ErrSourceUnavailable, errors.New("AccessDenied: arn:aws:s3:::storicsv"))),
+			want: want{
+				statusCode: http.StatusBadGateway,
+				err:        errors.New("AccessDenied: arn:aws:s3:::storicsv"),
+				body:       `{"code":"source_unavailable","error":"the file could not be read"}`,
+			},
+		},
+		{
+			name:         "persistence",
+			fields:       fields{event: event},
+			expectations: failure(apperr.Wrap(apperr.ErrPersistence, errors.New("dial tcp 10.0.0.4:5432: connection refused"))),
+			want: want{
+				statusCode: http.StatusServiceUnavailable,
+				err:        errors.New("dial tcp 10.0.0.4:5432: connection refused"),
+				body:       `{"code":"persistence_failed","error":"the transactions could not be stored"}`,
+			},
+		},
+		{
+			name:         "notification",
+			fields:       fields{event: event},
+			expectations: failure(apperr.Wrap(apperr.ErrNotification, errors.New("Throttling: Maximum sending rate exceeded"))),
+			want: want{
+				statusCode: http.StatusBadGateway,
+				err:        errors.New("Throttling: Maximum sending rate exceeded"),
+				body:       `{"code":"notification_failed","error":"the summary email could not be sent"}`,
 			},
 		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.expectations(tc.fields)
-			got, err := handler.LambdaEvent()
+			got, err := handler.LambdaEvent(context.Background(), tc.fields.event)
 			assert.Equal(t, tc.want.statusCode, got.StatusCode)
 			if err != nil {
 				assert.Equal(t, tc.want.err.Error(), err.Error())
 			}
+			if tc.want.body != "" {
+				assert.JSONEq(t, tc.want.body, got.Body)
+			}
 		})
 	}
 }
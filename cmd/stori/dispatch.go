@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/email"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/outbox"
+	"time"
+)
+
+type dispatchOptions struct {
+	envFile string
+	watch   time.Duration
+	requeue bool
+}
+
+func parseDispatchFlags(args []string, stderr io.Writer) (*dispatchOptions, error) {
+	opts := &dispatchOptions{}
+	flags := flag.NewFlagSet("dispatch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.StringVar(&opts.envFile, "env", ".env", "env file with the postgres, email and outbox settings")
+	flags.DurationVar(&opts.watch, "watch", 0, "keep sending the queued emails at this interval instead of once")
+	flags.BoolVar(&opts.requeue, "requeue-dead", false, "give the dead emails a new round of attempts before sending")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if opts.watch < 0 {
+		return nil, errors.New("--watch must be positive")
+	}
+
+	return opts, nil
+}
+
+// runDispatch sends the emails queued in the email outbox through the configured transport.
+func runDispatch(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	opts, err := parseDispatchFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	loader := application.Loader{
+		File:    opts.envFile,
+		Require: []application.Section{application.SectionPostgres, application.SectionEmail},
+	}
+	configs, err := loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := logging.Setup(logging.Config(configs.Logging)); err != nil {
+		return err
+	}
+
+	transport, err := email.NewTransport(configs)
+	if err != nil {
+		return err
+	}
+
+	pg := configs.PgConfig
+	database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+	defer database.Close()
+
+	repository := outbox.NewRepository(database)
+	if opts.requeue {
+		requeued, err := repository.Requeue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "requeued %d dead emails\n", requeued)
+	}
+
+	dispatcher := outbox.NewDispatcher(repository, transport, outbox.Config(configs.Outbox))
+	if opts.watch > 0 {
+		return dispatcher.Run(ctx, opts.watch)
+	}
+
+	result, err := dispatcher.Dispatch(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "sent %d, retried %d, dead %d\n", result.Sent, result.Retried, result.Dead)
+
+	return nil
+}
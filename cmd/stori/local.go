@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/message"
+	"stori-challenge/internal/model"
+	"stori-challenge/internal/transaction"
+)
+
+// staticAccounts returns the same owner for every file, it replaces the accounts table when nothing is persisted.
+type staticAccounts struct {
+	account model.Account
+}
+
+func (a staticAccounts) GetAccount(ctx context.Context, id string) (*model.Account, error) {
+	account := a.account
+	account.ID = id
+	return &account, nil
+}
+
+// discardRepository keeps nothing, every file is processed as if it was new.
+type discardRepository struct{}
+
+func (discardRepository) InsertTransactions(context.Context, []model.Transaction) error {
+	return nil
+}
+
+func (discardRepository) FindProcessedFile(ctx context.Context, accountID, etag, checksum string) (*model.ProcessedFile, error) {
+	return nil, nil
+}
+
+func (discardRepository) MarkProcessed(context.Context, *model.ProcessedFile) (bool, error) {
+	return true, nil
+}
+
+func (discardRepository) ListTransactions(context.Context, string, transaction.TransactionFilter) ([]model.Transaction, error) {
+	return nil, nil
+}
+
+// inlineUnitOfWork runs the function without a database transaction.
+type inlineUnitOfWork struct{}
+
+func (inlineUnitOfWork) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// htmlFileSender writes the rendered html of the email to a file instead of sending it, the attachments
+// are written next to it.
+type htmlFileSender struct {
+	path string
+}
+
+func (s htmlFileSender) SendEmail(ctx context.Context, details message.Message) error {
+	if err := os.WriteFile(s.path, []byte(details.Html), 0o644); err != nil {
+		return err
+	}
+	for _, attachment := range details.Attachments {
+		if err := os.WriteFile(filepath.Join(filepath.Dir(s.path), attachment.Filename), attachment.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sender interface {
+	SendEmail(context.Context, message.Message) error
+}
+
+// senders hands the email to every sender in order, an empty list discards it.
+type senders []sender
+
+func (s senders) SendEmail(ctx context.Context, details message.Message) error {
+	for _, sender := range s {
+		if err := sender.SendEmail(ctx, details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/pg_migrations"
+	"text/tabwriter"
+)
+
+type migrateOptions struct {
+	direction string
+	envFile   string
+	steps     int
+}
+
+func parseMigrateFlags(args []string, stderr io.Writer) (*migrateOptions, error) {
+	opts := &migrateOptions{}
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, "usage: stori migrate up|down|status [flags]\n\n")
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&opts.envFile, "env", ".env", "env file with the postgres settings")
+	flags.IntVar(&opts.steps, "steps", 1, "number of migrations reverted by down")
+
+	if len(args) > 0 {
+		opts.direction, args = args[0], args[1:]
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	switch opts.direction {
+	case "up", "down", "status":
+	case "", "-h", "--help":
+		flags.Usage()
+		return nil, flag.ErrHelp
+	default:
+		return nil, errors.Errorf("unknown migrate command %q, expected up, down or status", opts.direction)
+	}
+	if opts.steps < 1 {
+		return nil, errors.New("--steps must be positive")
+	}
+
+	return opts, nil
+}
+
+func runMigrate(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	opts, err := parseMigrateFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	loader := application.Loader{File: opts.envFile, Require: []application.Section{application.SectionPostgres}}
+	configs, err := loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	pg := configs.PgConfig
+	database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+	defer database.Close()
+
+	migrator, err := db.NewMigrator(database, pgmigrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch opts.direction {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(stdout, "applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(stdout, "schema is up to date")
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, opts.steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(stdout, "reverted %s\n", migration)
+		}
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, status := range statuses {
+		applied := "pending"
+		if !status.AppliedAt.IsZero() {
+			applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\n", status.Migration, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"path/filepath"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/email"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/integrations/filesystem"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/model"
+	"stori-challenge/internal/outbox"
+	"stori-challenge/internal/transaction"
+	"stori-challenge/pg_migrations"
+	"strconv"
+	"text/tabwriter"
+)
+
+type processOptions struct {
+	file       string
+	dryRun     bool
+	json       bool
+	envFile    string
+	accountID  string
+	period     string
+	source     string
+	dateFormat string
+	csvSchemas string
+	lenient    bool
+	persist    bool
+	sendEmail  bool
+	html       string
+	owner      model.Account
+	verbose    bool
+}
+
+func parseProcessFlags(args []string, stderr io.Writer) (*processOptions, error) {
+	opts := &processOptions{}
+	flags := flag.NewFlagSet("process", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.StringVar(&opts.file, "file", "", "csv statement to process (required)")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print the summary without persisting, emailing or writing the error report")
+	flags.BoolVar(&opts.json, "json", false, "print the summary as json")
+	flags.StringVar(&opts.envFile, "env", ".env", "env file with the postgres, aws and processing settings")
+	flags.StringVar(&opts.accountID, "account", "", "account of the statement, defaults to the folder of the file")
+	flags.StringVar(&opts.period, "period", "", "end of the statement period as YYYY-MM or YYYY-MM-DD")
+	flags.StringVar(&opts.source, "source", "", "partner that sent the file, selects the csv schema")
+	flags.StringVar(&opts.dateFormat, "date-format", "", "date layout, overrides dateFormat")
+	flags.StringVar(&opts.csvSchemas, "csv-schemas", "", "json file with the csv schemas, overrides csvSchemas")
+	flags.BoolVar(&opts.lenient, "lenient", false, "reject invalid rows instead of failing the file")
+	flags.BoolVar(&opts.persist, "persist", false, "store the transactions in postgres, the owner is read from the accounts table")
+	flags.BoolVar(&opts.sendEmail, "send-email", false, "send the summary email through the configured transport (emailTransport)")
+	flags.StringVar(&opts.html, "html", "", "write the rendered summary email to this file")
+	flags.StringVar(&opts.owner.Name, "name", "", "owner name used when the account is not read from postgres")
+	flags.StringVar(&opts.owner.Email, "email", "", "owner email used when the account is not read from postgres")
+	flags.StringVar(&opts.owner.Currency, "currency", model.DefaultCurrency, "account currency used when the account is not read from postgres")
+	flags.StringVar(&opts.owner.Locale, "locale", "", "email locale used when the account is not read from postgres, e.g. es-MX")
+	flags.BoolVar(&opts.verbose, "verbose", false, "log a sample of the processed rows at debug level")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if opts.file == "" {
+		return nil, errors.New("--file is required")
+	}
+	if opts.dryRun && (opts.persist || opts.sendEmail) {
+		return nil, errors.New("--dry-run can not be combined with --persist or --send-email")
+	}
+	if opts.sendEmail && !opts.persist && opts.owner.Email == "" {
+		return nil, errors.New("--send-email needs --email or --persist")
+	}
+
+	return opts, nil
+}
+
+// loader reads the settings from the env file and the environment, flags take precedence over them.
+// Postgres and ses settings are only required when they are used.
+func (o *processOptions) loader() application.Loader {
+	loader := application.Loader{File: o.envFile, Overrides: map[string]string{}}
+
+	if o.dateFormat != "" {
+		loader.Overrides[application.KeyDateFormat] = o.dateFormat
+	}
+	if o.csvSchemas != "" {
+		loader.Overrides[application.KeyCsvSchemas] = o.csvSchemas
+	}
+	if o.lenient {
+		loader.Overrides[application.KeyLenient] = strconv.FormatBool(o.lenient)
+	}
+	if o.persist {
+		loader.Require = append(loader.Require, application.SectionPostgres)
+	}
+	if o.sendEmail {
+		loader.Require = append(loader.Require, application.SectionEmail)
+	}
+
+	return loader
+}
+
+// location maps the file to the bucket and key the processor expects: the bucket is the grandparent
+// directory, so the key keeps the folder the account is resolved from, e.g. statements/42/2024-01.csv.
+func (o *processOptions) location() (bucket, key string, err error) {
+	path, err := filepath.Abs(o.file)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := filepath.Dir(path)
+	return filepath.Dir(dir), filepath.Base(dir) + "/" + filepath.Base(path), nil
+}
+
+func (o *processOptions) metadata() map[string]string {
+	metadata := map[string]string{}
+	if o.accountID != "" {
+		metadata[account.MetadataKey] = o.accountID
+	}
+	if o.period != "" {
+		metadata[transaction.PeriodMetadataKey] = o.period
+	}
+	if o.source != "" {
+		metadata[transaction.SourceMetadataKey] = o.source
+	}
+	return metadata
+}
+
+func runProcess(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	opts, err := parseProcessFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	configs, err := opts.loader().Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := logging.Setup(logging.Config(configs.Logging)); err != nil {
+		return err
+	}
+	// the summary is the output of the command, logs stay quiet unless they are asked for
+	switch {
+	case opts.verbose:
+		log.SetLevel(log.DebugLevel)
+	case configs.Logging.Level == "":
+		log.SetLevel(log.WarnLevel)
+	}
+	processing := transaction.Config(configs.Processing)
+
+	var (
+		transport email.Transport
+		outputs   senders
+	)
+	if opts.sendEmail {
+		transport, err = email.NewTransport(configs)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, transport)
+	}
+	if opts.html != "" {
+		outputs = append(outputs, htmlFileSender{path: opts.html})
+	}
+
+	bucket, key, err := opts.location()
+	if err != nil {
+		return err
+	}
+
+	storage := filesystem.NewService(opts.metadata(), opts.dryRun)
+	emailService := email.NewService(outputs, configs.EmailConfig.From, configs.EmailConfig.Unsubscribe, configs.EmailConfig.Locale)
+	service := transaction.NewService(emailService, storage, discardRepository{}, inlineUnitOfWork{},
+		staticAccounts{account: opts.owner}, processing)
+	var dispatcher *outbox.Dispatcher
+	if opts.persist {
+		pg := configs.PgConfig
+		database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+		defer database.Close()
+		if pg.AutoMigrate {
+			if err := db.MigrateUp(ctx, database, pgmigrations.FS); err != nil {
+				return err
+			}
+		}
+
+		repository := transaction.NewRepository(database, transaction.InsertConfig{
+			Strategy:  transaction.InsertStrategy(pg.InsertStrategy),
+			BatchSize: pg.InsertBatchSize,
+		})
+		if opts.sendEmail {
+			// the email is queued with the transactions and sent once the file is committed
+			queue := outbox.NewRepository(database)
+			queued := senders{queue}
+			if opts.html != "" {
+				queued = append(queued, htmlFileSender{path: opts.html})
+			}
+			emailService = email.NewService(queued, configs.EmailConfig.From, configs.EmailConfig.Unsubscribe, configs.EmailConfig.Locale)
+			dispatcher = outbox.NewDispatcher(queue, transport, outbox.Config(configs.Outbox))
+		}
+		service = transaction.NewService(emailService, storage, repository,
+			db.NewUnitOfWork(database), account.NewRepository(database), processing)
+	}
+
+	summary, err := service.ProcessCsv(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	// the dispatch also sends the other due emails of the outbox, a failed delivery stays queued
+	if dispatcher != nil {
+		result, err := dispatcher.Dispatch(ctx)
+		if err != nil {
+			return err
+		}
+		if result.Retried+result.Dead > 0 {
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "dispatch_email"}).
+				Warnf("%d emails could not be sent, they stay in the email outbox", result.Retried+result.Dead)
+		}
+	}
+
+	if opts.json {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+
+	return printSummary(stdout, summary)
+}
+
+func printSummary(out io.Writer, summary *model.Summary) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "account\t%s\n", summary.AccountID)
+	fmt.Fprintf(w, "balance\t%s\n", summary.RunningBalance.Format())
+	fmt.Fprintf(w, "debit\t%d transactions, average %s\n", summary.DebitCount, summary.GetAverage(model.DEBIT).Format())
+	fmt.Fprintf(w, "credit\t%d transactions, average %s\n", summary.CreditCount, summary.GetAverage(model.CREDIT).Format())
+	for _, month := range summary.Monthly {
+		fmt.Fprintf(w, "%s\t%d transactions, credits %s, debits %s, balance %s to %s\n", month.Month, month.Count,
+			month.CreditTotal.Format(), month.DebitTotal.Format(), month.OpeningBalance.Format(), month.ClosingBalance.Format())
+	}
+	fmt.Fprintf(w, "rejected\t%d\n", summary.Rejected)
+	if summary.ErrorReport != "" {
+		fmt.Fprintf(w, "error report\t%s\n", summary.ErrorReport)
+	}
+	if len(summary.UnknownColumns) > 0 {
+		fmt.Fprintf(w, "unknown columns\t%v\n", summary.UnknownColumns)
+	}
+	if summary.AlreadyProcessed {
+		fmt.Fprintf(w, "already processed\ttrue\n")
+	}
+
+	return w.Flush()
+}
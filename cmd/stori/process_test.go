@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/model"
+	"testing"
+)
+
+const statement = "Id,Date,Transaction\n0,7/15,+60.5\n1,7/28,-10.3\n2,8/2,-20.46\n3,8/13,+10\n4,8/14,abc\n"
+
+func TestRunProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        func(dir, file string) []string
+		wantCode    int
+		wantBalance string
+		wantFiles   []string
+	}{
+		{
+			name: "missing_file_flag",
+			args: func(dir, file string) []string {
+				return []string{"process", "--json"}
+			},
+			wantCode: 1,
+		},
+		{
+			name: "strict_invalid_row",
+			args: func(dir, file string) []string {
+				return []string{"process", "--json", "--period", "2024-08", "--file", file}
+			},
+			wantCode: 1,
+		},
+		{
+			name: "dry_run_writes_nothing",
+			args: func(dir, file string) []string {
+				return []string{"process", "--json", "--period", "2024-08", "--lenient", "--dry-run", "--file", file}
+			},
+			wantBalance: "39.74",
+			wantFiles:   []string{"2024-08.csv"},
+		},
+		{
+			name: "lenient_with_html",
+			args: func(dir, file string) []string {
+				return []string{"process", "--json", "--period", "2024-08", "--lenient", "--file", file,
+					"--html", filepath.Join(dir, "summary.html")}
+			},
+			wantBalance: "39.74",
+			wantFiles:   []string{"2024-08.csv", "2024-08.errors.csv", "statement-42-2024-08.csv", "statement-42-2024-08.pdf", "summary.html"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "42")
+			require.NoError(t, os.Mkdir(dir, 0o755))
+			file := filepath.Join(dir, "2024-08.csv")
+			require.NoError(t, os.WriteFile(file, []byte(statement), 0o644))
+
+			args := append(tt.args(dir, file), "--env", filepath.Join(dir, ".env"))
+
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), args, &stdout, &stderr)
+			require.Equal(t, tt.wantCode, code, stderr.String())
+			if tt.wantCode != 0 {
+				return
+			}
+
+			summary := &model.Summary{}
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), summary))
+			assert.Equal(t, "42", summary.AccountID)
+			assert.Equal(t, tt.wantBalance, summary.RunningBalance.String())
+			assert.Equal(t, 1, summary.Rejected)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			assert.Equal(t, tt.wantFiles, files)
+		})
+	}
+}
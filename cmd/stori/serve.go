@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/api"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/email"
+	"stori-challenge/internal/integrations/aws/s3"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/integrations/filesystem"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/outbox"
+	"stori-challenge/internal/transaction"
+	"stori-challenge/pg_migrations"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests are given to finish once the server is stopped.
+const shutdownTimeout = 30 * time.Second
+
+type serveOptions struct {
+	addr       string
+	envFile    string
+	bucket     string
+	storageDir string
+	noEmail    bool
+	// dispatchInterval is how often the emails queued in the email outbox are sent.
+	dispatchInterval time.Duration
+}
+
+func parseServeFlags(args []string, stderr io.Writer) (*serveOptions, error) {
+	opts := &serveOptions{}
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.StringVar(&opts.addr, "addr", ":8080", "address the http server listens on")
+	flags.StringVar(&opts.envFile, "env", ".env", "env file with the postgres, aws and processing settings")
+	flags.StringVar(&opts.bucket, "bucket", "", "s3 bucket the uploaded statements are stored in, overrides bucket")
+	flags.StringVar(&opts.storageDir, "storage-dir", "", "store the uploaded statements in this directory instead of s3")
+	flags.BoolVar(&opts.noEmail, "no-email", false, "do not send the summary emails")
+	flags.DurationVar(&opts.dispatchInterval, "dispatch-interval", 30*time.Second, "how often the emails queued in the email outbox are sent")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+func runServe(ctx context.Context, args []string, stderr io.Writer) error {
+	opts, err := parseServeFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	loader := application.Loader{File: opts.envFile, Require: []application.Section{application.SectionPostgres}}
+	if opts.bucket != "" {
+		loader.Overrides = map[string]string{application.KeyBucket: opts.bucket}
+	}
+	if !opts.noEmail {
+		loader.Require = append(loader.Require, application.SectionEmail)
+	}
+	if opts.storageDir == "" {
+		loader.Require = append(loader.Require, application.SectionUploads)
+	}
+
+	configs, err := loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if err := logging.Setup(logging.Config(configs.Logging)); err != nil {
+		return err
+	}
+
+	pg := configs.PgConfig
+	database := db.InitPostgres(pg.Host, pg.Database, pg.User, pg.Password)
+	defer database.Close()
+	if pg.AutoMigrate {
+		if err := db.MigrateUp(ctx, database, pgmigrations.FS); err != nil {
+			return err
+		}
+	}
+
+	repository := transaction.NewRepository(database, transaction.InsertConfig{
+		Strategy:  transaction.InsertStrategy(pg.InsertStrategy),
+		BatchSize: pg.InsertBatchSize,
+	})
+	accounts := account.NewRepository(database)
+
+	// the processing queues the emails with the transactions of the file and a dispatcher sends them in the
+	// background
+	var transport email.Transport = senders{}
+	if !opts.noEmail {
+		delivery, err := email.NewTransport(configs)
+		if err != nil {
+			return err
+		}
+		queue := outbox.NewRepository(database)
+		transport = queue
+		go outbox.NewDispatcher(queue, delivery, outbox.Config(configs.Outbox)).Run(ctx, opts.dispatchInterval)
+	}
+	emailService := email.NewService(transport, configs.EmailConfig.From, configs.EmailConfig.Unsubscribe, configs.EmailConfig.Locale)
+	unitOfWork := db.NewUnitOfWork(database)
+	processing := transaction.Config(configs.Processing)
+
+	bucket := configs.S3Config.Bucket
+	var service *transaction.Service
+	if opts.storageDir != "" {
+		bucket = opts.storageDir
+		service = transaction.NewService(emailService, filesystem.NewService(nil, false), repository, unitOfWork, accounts, processing)
+	} else {
+		s3Service, err := s3.NewS3Service(configs.S3Config)
+		if err != nil {
+			return err
+		}
+		service = transaction.NewService(emailService, s3Service, repository, unitOfWork, accounts, processing)
+	}
+
+	server := &http.Server{
+		Addr:              opts.addr,
+		Handler:           api.NewServer(service, bucket),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.WithFields(log.Fields{"event": "serve", "addr": opts.addr}).Info("http server listening")
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
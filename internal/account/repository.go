@@ -0,0 +1,35 @@
+package account
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/model"
+)
+
+var ErrNotFound = errors.New("account not found")
+
+type Repository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) GetAccount(ctx context.Context, id string) (*model.Account, error) {
+	account := &model.Account{ID: id}
+
+	database := db.GetConnection(ctx, r.db)
+	if err := database.ModelContext(ctx, account).WherePK().Select(); err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrapf(ErrNotFound, "account %s", id))
+		}
+		return nil, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+
+	return account, nil
+}
@@ -0,0 +1,30 @@
+package account
+
+import (
+	"github.com/pkg/errors"
+	"path"
+	"stori-challenge/internal/apperr"
+	"strings"
+)
+
+// MetadataKey is the s3 object metadata (x-amz-meta-account-id) that carries the account of the file.
+const MetadataKey = "account-id"
+
+var ErrUnresolved = errors.New("account id could not be resolved")
+
+// IDFromObject resolves the account of an uploaded file. The object metadata takes precedence,
+// otherwise the account is the parent folder of the key, e.g. statements/42/2024-01-31.csv belongs to account 42.
+func IDFromObject(key string, metadata map[string]string) (string, error) {
+	for name, value := range metadata {
+		if strings.EqualFold(name, MetadataKey) && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	dir := path.Dir(key)
+	if dir == "." || dir == "/" {
+		return "", apperr.Wrap(apperr.ErrInvalidRequest, errors.Wrapf(ErrUnresolved, "key %s", key))
+	}
+
+	return path.Base(dir), nil
+}
@@ -0,0 +1,28 @@
+package account
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIDFromObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		metadata map[string]string
+		want     string
+		err      error
+	}{
+		{name: "metadata", key: "transactions.csv", metadata: map[string]string{"account-id": "7"}, want: "7"},
+		{name: "metadata_precedence", key: "42/transactions.csv", metadata: map[string]string{"Account-Id": "7"}, want: "7"},
+		{name: "key_folder", key: "statements/42/2024-01-31.csv", want: "42"},
+		{name: "unresolved", key: "transactions.csv", err: ErrUnresolved},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := IDFromObject(tc.key, tc.metadata)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/model"
+	"stori-challenge/internal/transaction"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -source=server.go -destination=server_mock.go -package=api
+
+// maxUploadSize bounds the body of POST /statements.
+const maxUploadSize = 32 << 20
+
+// RequestIDHeader carries the id the logs of a request are correlated by, a missing or longer than
+// maxRequestID id is replaced by a random one.
+const (
+	RequestIDHeader = "X-Request-Id"
+	maxRequestID    = 64
+)
+
+type service interface {
+	Upload(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) (*model.Summary, error)
+	GetStatement(ctx context.Context, id string) (*model.Summary, error)
+	StatementFile(ctx context.Context, id, format string) (*model.Attachment, error)
+	ListTransactions(ctx context.Context, accountID string, filter transaction.TransactionFilter) ([]model.Transaction, error)
+}
+
+// Server exposes the statements and the transaction history over http.
+type Server struct {
+	service service
+	bucket  string
+	now     func() time.Time
+	// uploadID tells apart the uploads sent with the same name.
+	uploadID func() string
+}
+
+// NewServer builds the http api, uploaded files are stored in bucket.
+func NewServer(service service, bucket string) *Server {
+	return &Server{service: service, bucket: bucket, now: time.Now, uploadID: newUploadID}
+}
+
+// TransactionPage is the body of GET /accounts/{id}/transactions.
+type TransactionPage struct {
+	AccountID    string              `json:"account_id"`
+	Page         int                 `json:"page"`
+	PageSize     int                 `json:"page_size"`
+	Transactions []model.Transaction `json:"transactions"`
+}
+
+// errorBody is the body of a failed request, it only shows the message of the kind of the error, never its
+// cause.
+type errorBody struct {
+	Code  apperr.Code `json:"code,omitempty"`
+	Error string      `json:"error"`
+}
+
+// ServeHTTP routes the request, path parameters are parsed by hand to keep the standard mux.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestID || strings.ContainsAny(id, "\r\n") {
+		id = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	r = r.WithContext(logging.WithRequestID(r.Context(), id))
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "statements":
+		s.route(w, r, http.MethodPost, s.uploadStatement)
+	case len(parts) == 2 && parts[0] == "statements" && parts[1] != "":
+		s.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			s.getStatement(w, r, parts[1])
+		})
+	case len(parts) == 3 && parts[0] == "statements" && parts[1] != "" && strings.HasPrefix(parts[2], "statement."):
+		s.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			s.downloadStatement(w, r, parts[1], strings.TrimPrefix(parts[2], "statement."))
+		})
+	case len(parts) == 3 && parts[0] == "accounts" && parts[1] != "" && parts[2] == "transactions":
+		s.route(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+			s.listTransactions(w, r, parts[1])
+		})
+	default:
+		s.writeError(w, r, http.StatusNotFound, apperr.Wrap(apperr.ErrNotFound, errors.Errorf("%s not found", r.URL.Path)))
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, method string, handle http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		s.writeError(w, r, http.StatusMethodNotAllowed, apperr.InvalidRequest("method %s not allowed", r.Method))
+		return
+	}
+	handle(w, r)
+}
+
+// uploadStatement processes the csv sent as the "file" field of a multipart form or as the raw body.
+// The account, source and period form or query values are stored as the metadata of the file.
+func (s *Server) uploadStatement(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	body, name, err := readUpload(r)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, apperr.Wrap(apperr.ErrInvalidRequest, err))
+		return
+	}
+
+	accountID := strings.TrimSpace(r.FormValue("account"))
+	if accountID == "" || strings.ContainsAny(accountID, `/\`) {
+		s.writeError(w, r, http.StatusBadRequest, apperr.InvalidRequest("a valid account is required"))
+		return
+	}
+	if name == "" {
+		name = fmt.Sprintf("statement-%s.csv", s.now().UTC().Format("20060102T150405Z"))
+	}
+
+	metadata := map[string]string{account.MetadataKey: accountID}
+	if source := r.FormValue("source"); source != "" {
+		metadata[transaction.SourceMetadataKey] = source
+	}
+	if period := r.FormValue("period"); period != "" {
+		metadata[transaction.PeriodMetadataKey] = period
+	}
+
+	summary, err := s.service.Upload(r.Context(), s.bucket, s.uploadKey(accountID, name), body, metadata)
+	if err != nil {
+		s.writeError(w, r, apperr.StatusOf(err), err)
+		return
+	}
+
+	status := http.StatusCreated
+	if summary.AlreadyProcessed {
+		status = http.StatusOK
+	}
+	w.Header().Set("Location", "/statements/"+summary.ID)
+	s.writeJSON(w, r, status, summary)
+}
+
+// uploadKey stores every upload under a key of its own, a file sent again with the same name must not
+// replace the object the statements of the previous one are read from.
+func (s *Server) uploadKey(accountID, name string) string {
+	extension := path.Ext(name)
+	return accountID + "/" + strings.TrimSuffix(name, extension) + "-" + s.uploadID() + extension
+}
+
+func newUploadID() string {
+	id := make([]byte, 4)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// readUpload returns the content of the upload and the base name of the file when the client sent one.
+func readUpload(r *http.Request) ([]byte, string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", apperr.InvalidRequest("the file field is required")
+		}
+		defer file.Close()
+
+		body, err := io.ReadAll(file)
+		if err != nil {
+			return nil, "", err
+		}
+
+		name := path.Base(strings.ReplaceAll(header.Filename, `\`, "/"))
+		if name == "." || name == "/" {
+			name = ""
+		}
+		return body, name, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) == 0 {
+		return nil, "", apperr.InvalidRequest("the body is empty")
+	}
+
+	return body, "", nil
+}
+
+func (s *Server) getStatement(w http.ResponseWriter, r *http.Request, id string) {
+	summary, err := s.service.GetStatement(r.Context(), id)
+	if err != nil {
+		s.writeError(w, r, apperr.StatusOf(err), err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, summary)
+}
+
+// downloadStatement serves GET /statements/{id}/statement.pdf and statement.csv.
+func (s *Server) downloadStatement(w http.ResponseWriter, r *http.Request, id, format string) {
+	file, err := s.service.StatementFile(r.Context(), id, format)
+	if err != nil {
+		s.writeError(w, r, apperr.StatusOf(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.Data)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(file.Data); err != nil {
+		log.WithContext(r.Context()).
+			WithFields(log.Fields{"event": "http_request", "method": r.Method, "path": r.URL.Path}).
+			Errorf("failed to write response %s", err.Error())
+	}
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request, accountID string) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	transactions, err := s.service.ListTransactions(r.Context(), accountID, filter)
+	if err != nil {
+		s.writeError(w, r, apperr.StatusOf(err), err)
+		return
+	}
+	if transactions == nil {
+		transactions = []model.Transaction{}
+	}
+
+	s.writeJSON(w, r, http.StatusOK, TransactionPage{
+		AccountID:    accountID,
+		Page:         filter.Page,
+		PageSize:     filter.PageSize,
+		Transactions: transactions,
+	})
+}
+
+// parseFilter reads the from and to dates (YYYY-MM-DD) and the page of the history, the page starts at 1.
+func parseFilter(r *http.Request) (transaction.TransactionFilter, error) {
+	query := r.URL.Query()
+	filter := transaction.TransactionFilter{Page: 1, PageSize: transaction.DefaultPageSize}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return filter, apperr.InvalidRequest("%s must be a YYYY-MM-DD date", name)
+		}
+		*target = date
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, apperr.InvalidRequest("to is before from")
+	}
+
+	for name, target := range map[string]*int{"page": &filter.Page, "page_size": &filter.PageSize} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			return filter, apperr.InvalidRequest("%s must be a positive number", name)
+		}
+		*target = number
+	}
+	if filter.PageSize > transaction.MaxPageSize {
+		filter.PageSize = transaction.MaxPageSize
+	}
+
+	return filter, nil
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.WithContext(r.Context()).
+			WithFields(log.Fields{"event": "http_request", "method": r.Method, "path": r.URL.Path}).
+			Error(err)
+	}
+
+	s.writeJSON(w, r, status, errorBody{Code: apperr.CodeOf(err), Error: apperr.MessageOf(err)})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.WithContext(r.Context()).
+			WithFields(log.Fields{"event": "http_request", "method": r.Method, "path": r.URL.Path}).
+			Errorf("failed to write response %s", err.Error())
+	}
+}
@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: server.go
+
+// Package api is a generated GoMock package.
+package api
+
+import (
+	context "context"
+	reflect "reflect"
+	model "stori-challenge/internal/model"
+	transaction "stori-challenge/internal/transaction"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockservice is a mock of service interface.
+type Mockservice struct {
+	ctrl     *gomock.Controller
+	recorder *MockserviceMockRecorder
+}
+
+// MockserviceMockRecorder is the mock recorder for Mockservice.
+type MockserviceMockRecorder struct {
+	mock *Mockservice
+}
+
+// NewMockservice creates a new mock instance.
+func NewMockservice(ctrl *gomock.Controller) *Mockservice {
+	mock := &Mockservice{ctrl: ctrl}
+	mock.recorder = &MockserviceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockservice) EXPECT() *MockserviceMockRecorder {
+	return m.recorder
+}
+
+// GetStatement mocks base method.
+func (m *Mockservice) GetStatement(ctx context.Context, id string) (*model.Summary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatement", ctx, id)
+	ret0, _ := ret[0].(*model.Summary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatement indicates an expected call of GetStatement.
+func (mr *MockserviceMockRecorder) GetStatement(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatement", reflect.TypeOf((*Mockservice)(nil).GetStatement), ctx, id)
+}
+
+// ListTransactions mocks base method.
+func (m *Mockservice) ListTransactions(ctx context.Context, accountID string, filter transaction.TransactionFilter) ([]model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", ctx, accountID, filter)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockserviceMockRecorder) ListTransactions(ctx, accountID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*Mockservice)(nil).ListTransactions), ctx, accountID, filter)
+}
+
+// StatementFile mocks base method.
+func (m *Mockservice) StatementFile(ctx context.Context, id, format string) (*model.Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatementFile", ctx, id, format)
+	ret0, _ := ret[0].(*model.Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StatementFile indicates an expected call of StatementFile.
+func (mr *MockserviceMockRecorder) StatementFile(ctx, id, format interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatementFile", reflect.TypeOf((*Mockservice)(nil).StatementFile), ctx, id, format)
+}
+
+// Upload mocks base method.
+func (m *Mockservice) Upload(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) (*model.Summary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upload", ctx, bucket, key, body, metadata)
+	ret0, _ := ret[0].(*model.Summary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockserviceMockRecorder) Upload(ctx, bucket, key, body, metadata interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*Mockservice)(nil).Upload), ctx, bucket, key, body, metadata)
+}
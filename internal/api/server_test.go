@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/model"
+	"stori-challenge/internal/statement"
+	"stori-challenge/internal/transaction"
+	"strings"
+	"testing"
+	"time"
+)
+
+func multipartUpload(t *testing.T, target, filename, content string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, target, &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewMockservice(ctrl)
+	server := NewServer(service, "storicsv")
+	server.now = func() time.Time { return time.Date(2024, 8, 15, 10, 0, 0, 0, time.UTC) }
+	server.uploadID = func() string { return "1a2b3c4d" }
+
+	tests := []struct {
+		name         string
+		request      func() *http.Request
+		expectations func()
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name: "upload_multipart",
+			request: func() *http.Request {
+				return multipartUpload(t, "/statements?account=42&period=2024-08", "C:\\files\\2024-08.csv", "Id,Date,Transaction\n")
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", "42/2024-08-1a2b3c4d.csv", []byte("Id,Date,Transaction\n"),
+						map[string]string{account.MetadataKey: "42", transaction.PeriodMetadataKey: "2024-08"}).
+					Return(&model.Summary{ID: "abc", AccountID: "42"}, nil)
+			},
+			wantStatus:   http.StatusCreated,
+			wantBody:     `"id":"abc"`,
+			wantLocation: "/statements/abc",
+		},
+		{
+			name: "upload_raw_already_processed",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements?account=42", strings.NewReader("0,7/15,+60.5\n"))
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", "42/statement-20240815T100000Z-1a2b3c4d.csv", gomock.Any(), gomock.Any()).
+					Return(&model.Summary{ID: "abc", AlreadyProcessed: true}, nil)
+			},
+			wantStatus:   http.StatusOK,
+			wantLocation: "/statements/abc",
+		},
+		{
+			name: "upload_without_account",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements", strings.NewReader("0,7/15,+60.5\n"))
+			},
+			expectations: func() {},
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     "account",
+		},
+		{
+			name: "upload_invalid_file",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements?account=42", strings.NewReader("0,7/15,abc\n"))
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, apperr.InvalidRow(&transaction.RowError{Line: 1, Column: "amount", Value: "abc", Reason: "invalid"}))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "upload_persistence_failure",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements?account=42", strings.NewReader("0,7/15,+60.5\n"))
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, apperr.Wrap(apperr.ErrPersistence, errors.New("dial tcp 10.0.0.4:5432: connection refused")))
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"code":"persistence_failed","error":"the transactions could not be stored"}`,
+		},
+		{
+			name: "upload_unexpected_failure",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements?account=42", strings.NewReader("0,7/15,+60.5\n"))
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("pq: relation \"processed_files\" does not exist"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"code":"internal","error":"internal error"}`,
+		},
+		{
+			name: "get_statement",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/statements/abc", nil)
+			},
+			expectations: func() {
+				service.EXPECT().GetStatement(gomock.Any(), "abc").Return(&model.Summary{ID: "abc", AccountID: "42"}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"account_id":"42"`,
+		},
+		{
+			name: "get_statement_not_found",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/statements/abc", nil)
+			},
+			expectations: func() {
+				service.EXPECT().GetStatement(gomock.Any(), "abc").Return(nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrap(transaction.ErrStatementNotFound, "abc")))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "list_transactions",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/accounts/42/transactions?from=2024-07-01&to=2024-07-31&page=2", nil)
+			},
+			expectations: func() {
+				service.EXPECT().
+					ListTransactions(gomock.Any(), "42", transaction.TransactionFilter{
+						From:     time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+						To:       time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC),
+						Page:     2,
+						PageSize: transaction.DefaultPageSize,
+					}).
+					Return(nil, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"page":2,"page_size":50,"transactions":[]`,
+		},
+		{
+			name: "list_transactions_unknown_account",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/accounts/7/transactions", nil)
+			},
+			expectations: func() {
+				service.EXPECT().
+					ListTransactions(gomock.Any(), "7", gomock.Any()).
+					Return(nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrap(account.ErrNotFound, "account 7")))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":"not_found","error":"the requested resource does not exist"}`,
+		},
+		{
+			name: "upload_unresolved_account_hides_the_cause",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/statements?account=42", strings.NewReader("0,7/15,+60.5\n"))
+			},
+			expectations: func() {
+				service.EXPECT().
+					Upload(gomock.Any(), "storicsv", gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(nil, apperr.Wrap(apperr.ErrInvalidRequest, errors.New(`pg: relation "accounts" has no row "42"`)))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"code":"invalid_request","error":"the request is invalid"}`,
+		},
+		{
+			name: "list_transactions_invalid_date",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/accounts/42/transactions?from=07/01", nil)
+			},
+			expectations: func() {},
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `{"code":"invalid_request","error":"from must be a YYYY-MM-DD date"}`,
+		},
+		{
+			name: "method_not_allowed",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodDelete, "/statements/abc", nil)
+			},
+			expectations: func() {},
+			wantStatus:   http.StatusMethodNotAllowed,
+		},
+		{
+			name: "not_found",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/accounts/42", nil)
+			},
+			expectations: func() {},
+			wantStatus:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.expectations()
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, tt.request())
+
+			assert.Equal(t, tt.wantStatus, recorder.Code, recorder.Body.String())
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			assert.Contains(t, recorder.Body.String(), tt.wantBody)
+			assert.Equal(t, tt.wantLocation, recorder.Header().Get("Location"))
+			assert.Len(t, recorder.Header().Get(RequestIDHeader), 32)
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewMockservice(ctrl)
+	server := NewServer(service, "storicsv")
+
+	service.EXPECT().
+		GetStatement(gomock.Any(), "abc").
+		DoAndReturn(func(ctx context.Context, id string) (*model.Summary, error) {
+			assert.Equal(t, "req-1", logging.FieldsOf(ctx)[logging.FieldRequestID])
+			return &model.Summary{ID: id}, nil
+		})
+
+	request := httptest.NewRequest(http.MethodGet, "/statements/abc", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "req-1", recorder.Header().Get(RequestIDHeader))
+}
+
+func TestDownloadStatement(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	service := NewMockservice(ctrl)
+	server := NewServer(service, "storicsv")
+
+	service.EXPECT().
+		StatementFile(gomock.Any(), "abc", "csv").
+		Return(&model.Attachment{Filename: "statement-42-2024-08.csv", ContentType: "text/csv; charset=UTF-8", Data: []byte("id,date\n")}, nil)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/statements/abc/statement.csv", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/csv; charset=UTF-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=statement-42-2024-08.csv", recorder.Header().Get("Content-Disposition"))
+	assert.Equal(t, "id,date\n", recorder.Body.String())
+
+	service.EXPECT().
+		StatementFile(gomock.Any(), "abc", "xlsx").
+		Return(nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrap(statement.ErrUnknownFormat, `"xlsx"`)))
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/statements/abc/statement.xlsx", nil))
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+// TestUploadKey stores the uploads sent with the same name under different keys.
+func TestUploadKey(t *testing.T) {
+	server := NewServer(nil, "storicsv")
+
+	first, second := server.uploadKey("42", "2024-08.csv"), server.uploadKey("42", "2024-08.csv")
+
+	assert.Regexp(t, `^42/2024-08-[0-9a-f]{8}\.csv$`, first)
+	assert.NotEqual(t, first, second)
+}
@@ -0,0 +1,125 @@
+// Package apperr classifies the failures of the processing, callers report the code and the message of the
+// kind without exposing the cause.
+package apperr
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// Code identifies the kind of a failure in responses and logs.
+type Code string
+
+const (
+	CodeInvalidRow        Code = "invalid_row"
+	CodeSourceUnavailable Code = "source_unavailable"
+	CodePersistence       Code = "persistence_failed"
+	CodeNotification      Code = "notification_failed"
+	CodeInvalidRequest    Code = "invalid_request"
+	CodeNotFound          Code = "not_found"
+	CodeInterrupted       Code = "interrupted"
+	// CodeInternal is the code of the errors that were not classified.
+	CodeInternal Code = "internal"
+)
+
+// Error is a failure of a known kind, Message is safe to show to clients and Err is the cause.
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// The kinds of failure, match them with errors.Is.
+var (
+	ErrInvalidRow        = &Error{Code: CodeInvalidRow, Message: "the file has invalid content"}
+	ErrSourceUnavailable = &Error{Code: CodeSourceUnavailable, Message: "the file could not be read"}
+	ErrPersistence       = &Error{Code: CodePersistence, Message: "the transactions could not be stored"}
+	ErrNotification      = &Error{Code: CodeNotification, Message: "the summary email could not be sent"}
+	ErrInvalidRequest    = &Error{Code: CodeInvalidRequest, Message: "the request is invalid"}
+	ErrNotFound          = &Error{Code: CodeNotFound, Message: "the requested resource does not exist"}
+	ErrInterrupted       = &Error{Code: CodeInterrupted, Message: "the processing was interrupted"}
+)
+
+const internalMessage = "internal error"
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind, whatever their cause.
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(*Error)
+	return ok && kind.Code == e.Code
+}
+
+// Wrap classifies cause as kind, nil stays nil and an error that was already classified keeps its kind.
+func Wrap(kind *Error, cause error) error {
+	if cause == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(cause, &classified) {
+		return cause
+	}
+	return &Error{Code: kind.Code, Message: kind.Message, Err: cause}
+}
+
+// InvalidRow classifies a problem of the content of the file, its message describes the content sent by the
+// client so it is shown as is.
+func InvalidRow(cause error) error {
+	if cause == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(cause, &classified) {
+		return cause
+	}
+	return &Error{Code: CodeInvalidRow, Message: cause.Error(), Err: cause}
+}
+
+// InvalidRequest reports a problem of the request, the message is written for the client so it is shown
+// as is.
+func InvalidRequest(format string, args ...interface{}) error {
+	cause := errors.Errorf(format, args...)
+	return &Error{Code: CodeInvalidRequest, Message: cause.Error(), Err: cause}
+}
+
+// CodeOf returns the code of the kind of err, CodeInternal when it was not classified.
+func CodeOf(err error) Code {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Code
+	}
+	return CodeInternal
+}
+
+// MessageOf returns the message of err that is safe to show to clients.
+func MessageOf(err error) string {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Message
+	}
+	return internalMessage
+}
+
+// StatusOf maps the kind of err to an http status code, errors that were not classified are internal.
+func StatusOf(err error) int {
+	switch CodeOf(err) {
+	case CodeInvalidRow, CodeInvalidRequest:
+		return http.StatusUnprocessableEntity
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeSourceUnavailable, CodeNotification:
+		return http.StatusBadGateway
+	case CodePersistence, CodeInterrupted:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,105 @@
+package apperr
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("connection refused")
+
+	tests := []struct {
+		name    string
+		err     error
+		kind    error
+		code    Code
+		message string
+		text    string
+	}{
+		{
+			name:    "classified",
+			err:     Wrap(ErrPersistence, cause),
+			kind:    ErrPersistence,
+			code:    CodePersistence,
+			message: "the transactions could not be stored",
+			text:    "connection refused",
+		},
+		{
+			name:    "wrapped_again",
+			err:     errors.Wrap(Wrap(ErrSourceUnavailable, cause), "process"),
+			kind:    ErrSourceUnavailable,
+			code:    CodeSourceUnavailable,
+			message: "the file could not be read",
+			text:    "process: connection refused",
+		},
+		{
+			name:    "keeps_the_first_kind",
+			err:     Wrap(ErrPersistence, errors.Wrap(Wrap(ErrNotification, cause), "send")),
+			kind:    ErrNotification,
+			code:    CodeNotification,
+			message: "the summary email could not be sent",
+			text:    "send: connection refused",
+		},
+		{
+			name:    "invalid_row_shows_the_cause",
+			err:     InvalidRow(errors.Wrap(cause, "line 2")),
+			kind:    ErrInvalidRow,
+			code:    CodeInvalidRow,
+			message: "line 2: connection refused",
+			text:    "line 2: connection refused",
+		},
+		{
+			name:    "not_classified",
+			err:     cause,
+			code:    CodeInternal,
+			message: "internal error",
+			text:    "connection refused",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.kind != nil {
+				assert.ErrorIs(t, tc.err, tc.kind)
+			}
+			assert.ErrorIs(t, tc.err, cause, "the cause is kept")
+			assert.Equal(t, tc.code, CodeOf(tc.err))
+			assert.Equal(t, tc.message, MessageOf(tc.err))
+			assert.EqualError(t, tc.err, tc.text)
+		})
+	}
+
+	assert.Nil(t, Wrap(ErrPersistence, nil))
+	assert.Nil(t, InvalidRow(nil))
+	assert.NotErrorIs(t, Wrap(ErrPersistence, cause), ErrNotification)
+
+	invalid := Wrap(ErrPersistence, errors.Wrap(InvalidRequest("%s must be a positive number", "page"), "list"))
+	assert.ErrorIs(t, invalid, ErrInvalidRequest)
+	assert.Equal(t, "page must be a positive number", MessageOf(invalid))
+}
+
+func TestStatusOf(t *testing.T) {
+	cause := errors.New("fail")
+
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{err: InvalidRow(cause), status: http.StatusUnprocessableEntity},
+		{err: Wrap(ErrInvalidRequest, cause), status: http.StatusUnprocessableEntity},
+		{err: errors.Wrap(Wrap(ErrNotFound, cause), "account 7"), status: http.StatusNotFound},
+		{err: Wrap(ErrSourceUnavailable, cause), status: http.StatusBadGateway},
+		{err: Wrap(ErrNotification, cause), status: http.StatusBadGateway},
+		{err: Wrap(ErrPersistence, cause), status: http.StatusServiceUnavailable},
+		{err: Wrap(ErrInterrupted, cause), status: http.StatusServiceUnavailable},
+		{err: cause, status: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(CodeOf(tc.err)), func(t *testing.T) {
+			assert.Equal(t, tc.status, StatusOf(tc.err))
+		})
+	}
+}
@@ -1,24 +1,122 @@
 package application
 
+import (
+	"stori-challenge/internal/csvschema"
+	"stori-challenge/internal/dateparse"
+	"time"
+)
+
 type Config struct {
-	AwsSesConfig AwsSesConfig `json:"aws_ses_config"`
+	AwsSesConfig AwsSesConfig     `json:"aws_ses_config"`
+	EmailConfig  EmailConfig      `json:"email_config"`
+	Outbox       OutboxConfig     `json:"outbox"`
+	S3Config     S3Config         `json:"s3_config"`
+	PgConfig     PgConfig         `json:"pg_config"`
+	Processing   ProcessingConfig `json:"processing"`
+	Logging      LoggingConfig    `json:"logging"`
+}
+
+// Email transports, see EmailConfig.
+const (
+	TransportSES  = "ses"
+	TransportSMTP = "smtp"
+	TransportFile = "file"
+)
+
+// EmailConfig selects how the emails leave the service and what every email carries whatever the transport.
+type EmailConfig struct {
+	// Transport is TransportSES (the default), TransportSMTP or TransportFile.
+	Transport string     `json:"transport"`
+	Smtp      SmtpConfig `json:"smtp"`
+	// Dir is the directory TransportFile writes the .eml files to.
+	Dir string `json:"dir"`
+
+	// From is the sender of the emails, it is read from awsSesFrom.
+	From string `json:"from"`
+	// Unsubscribe holds the mailto: or https: targets of the List-Unsubscribe header.
+	Unsubscribe []string `json:"unsubscribe"`
+	// Locale is the locale of the emails sent to accounts without a known locale.
+	Locale string `json:"locale"`
+}
+
+// OutboxConfig holds the delivery settings of the emails queued in the email_outbox table, every summary
+// email is queued in the transaction of its file and sent by a dispatcher.
+type OutboxConfig struct {
+	// MaxAttempts is the number of deliveries tried before an email is dead-lettered.
+	MaxAttempts int `json:"max_attempts"`
+	// Backoff is the wait after the first failed delivery, it doubles after every further failure.
+	Backoff time.Duration `json:"backoff"`
+	// BatchSize is the number of emails claimed at once by the dispatcher.
+	BatchSize int `json:"batch_size"`
+}
+
+// LoggingConfig holds the level and the format of the logs.
+type LoggingConfig struct {
+	// Level is debug, info (the default), warn or error.
+	Level string `json:"level"`
+	// Format is "json" (the default) or "text".
+	Format string `json:"format"`
 }
 
+// SmtpConfig locates the SMTP server, authentication is only attempted when User is set.
+type SmtpConfig struct {
+	// Host is host:port, e.g. localhost:1025 for MailHog.
+	Host     string `json:"host"`
+	User     string `json:"user"`
+	Password string `json:"-"`
+	// TLS is "starttls" (required), "none" (plain text, local servers only) or empty to upgrade the
+	// connection when the server offers STARTTLS.
+	TLS string `json:"tls"`
+}
+
+// AwsSesConfig holds the aws settings of TransportSES.
 type AwsSesConfig struct {
 	AwsConfig
-	From string `json:"from"`
 }
 
+// S3Config locates the statements, Bucket is where the files uploaded through the http api are stored.
+type S3Config struct {
+	AwsConfig
+	Bucket string `json:"bucket"`
+}
+
+// AwsConfig holds the region and the static credentials, empty credentials fall back to the default
+// provider chain (environment, shared profile or the role of the lambda).
 type AwsConfig struct {
 	Region string `json:"region"`
 	Key    string `json:"key"`
 	Secret string `json:"secret"`
 }
 
+// PgConfig is enabled when a host is configured.
 type PgConfig struct {
 	Enabled  bool   `json:"enabled"`
 	Host     string `json:"host"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Database string `json:"database"`
+	// InsertStrategy is how the transactions are written, "batch" (default) or "copy".
+	InsertStrategy string `json:"insert_strategy"`
+	// InsertBatchSize is the number of transactions sent to postgres in a single statement, every batch of
+	// ProcessingConfig.BatchSize is split into statements of this size, so a larger value has no effect.
+	InsertBatchSize int `json:"insert_batch_size"`
+	// AutoMigrate applies the pending migrations when the service starts.
+	AutoMigrate bool `json:"auto_migrate"`
+}
+
+// ProcessingConfig holds the settings shared by every processed file.
+type ProcessingConfig struct {
+	DateFormat dateparse.Format   `json:"date_format"`
+	Schemas    csvschema.Registry `json:"schemas"`
+	Lenient    bool               `json:"lenient"`
+	// BatchSize is the number of transactions a file buffers before they are inserted, it bounds the memory of
+	// the processing. Each batch is written in statements of PgConfig.InsertBatchSize transactions, every
+	// batch of a file is committed in a single transaction once the whole file is read.
+	BatchSize int `json:"batch_size"`
+	// SummaryLimit is the number of transactions listed in the summary.
+	SummaryLimit int `json:"summary_limit"`
+	// AttachmentLimit is the largest size in bytes of the statement attached to the summary email.
+	AttachmentLimit int `json:"attachment_limit"`
+	// StatementURL links to the statement download when it is not attached, "{id}" is the statement id.
+	StatementURL string `json:"statement_url"`
 }
@@ -0,0 +1,397 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+	"os"
+	"stori-challenge/internal/csvschema"
+	"stori-challenge/internal/dateparse"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys of the settings as written in the config file and the secrets, each one can also be set
+// through the environment variable returned by EnvName.
+const (
+	KeyAwsRegion  = "awsRegion"
+	KeyAwsKey     = "awsKey"
+	KeyAwsSecret  = "awsSecret"
+	KeyAwsSesFrom = "awsSesFrom"
+	// KeyUnsubscribe is a comma separated list of mailto: or https: targets.
+	KeyUnsubscribe = "unsubscribe"
+	// KeyEmailTransport is "ses", "smtp" or "file".
+	KeyEmailTransport = "emailTransport"
+	KeySmtpHost       = "smtpHost"
+	KeySmtpUser       = "smtpUser"
+	KeySmtpPassword   = "smtpPassword"
+	// KeySmtpTLS is "starttls", "none" or empty for opportunistic STARTTLS.
+	KeySmtpTLS = "smtpTls"
+	// KeyEmailDir is the directory the "file" transport writes the .eml files to.
+	KeyEmailDir          = "emailDir"
+	KeyOutboxMaxAttempts = "outboxMaxAttempts"
+	// KeyOutboxBackoff is a duration such as 30s or 5m.
+	KeyOutboxBackoff   = "outboxBackoff"
+	KeyOutboxBatchSize = "outboxBatchSize"
+	// KeyLocale is the locale of the emails of the accounts without one, e.g. "es-MX".
+	KeyLocale     = "locale"
+	KeyBucket     = "bucket"
+	KeyPgHost     = "host"
+	KeyPgDatabase = "database"
+	KeyPgUser     = "user"
+	KeyPgPassword = "password"
+	// KeyPgInsertStrategy is "batch" or "copy".
+	KeyPgInsertStrategy  = "insertStrategy"
+	KeyPgInsertBatchSize = "insertBatchSize"
+	KeyPgAutoMigrate     = "autoMigrate"
+	KeyDateFormat        = "dateFormat"
+	KeyCsvSchemas        = "csvSchemas"
+	KeyLenient           = "lenient"
+	KeyBatchSize         = "batchSize"
+	KeySummary           = "summaryLimit"
+	KeyAttachmentLimit   = "attachmentLimit"
+	// KeyStatementURL is an http(s) link to the statement download, "{id}" is the statement id.
+	KeyStatementURL = "statementUrl"
+	// KeyLogLevel is debug, info, warn or error.
+	KeyLogLevel = "logLevel"
+	// KeyLogFormat is json or text.
+	KeyLogFormat = "logFormat"
+	// KeySecrets selects the secrets provider, see NewSecretsProvider.
+	KeySecrets = "secrets"
+)
+
+var envNames = map[string]string{
+	KeyAwsRegion:         "STORI_AWS_REGION",
+	KeyAwsKey:            "STORI_AWS_KEY",
+	KeyAwsSecret:         "STORI_AWS_SECRET",
+	KeyAwsSesFrom:        "STORI_AWS_SES_FROM",
+	KeyUnsubscribe:       "STORI_UNSUBSCRIBE",
+	KeyLocale:            "STORI_LOCALE",
+	KeyEmailTransport:    "STORI_EMAIL_TRANSPORT",
+	KeySmtpHost:          "STORI_SMTP_HOST",
+	KeySmtpUser:          "STORI_SMTP_USER",
+	KeySmtpPassword:      "STORI_SMTP_PASSWORD",
+	KeySmtpTLS:           "STORI_SMTP_TLS",
+	KeyEmailDir:          "STORI_EMAIL_DIR",
+	KeyOutboxMaxAttempts: "STORI_OUTBOX_MAX_ATTEMPTS",
+	KeyOutboxBackoff:     "STORI_OUTBOX_BACKOFF",
+	KeyOutboxBatchSize:   "STORI_OUTBOX_BATCH_SIZE",
+	KeyBucket:            "STORI_BUCKET",
+	KeyPgHost:            "STORI_PG_HOST",
+	KeyPgDatabase:        "STORI_PG_DATABASE",
+	KeyPgUser:            "STORI_PG_USER",
+	KeyPgPassword:        "STORI_PG_PASSWORD",
+	KeyPgInsertStrategy:  "STORI_PG_INSERT_STRATEGY",
+	KeyPgInsertBatchSize: "STORI_PG_INSERT_BATCH_SIZE",
+	KeyPgAutoMigrate:     "STORI_PG_AUTO_MIGRATE",
+	KeyDateFormat:        "STORI_DATE_FORMAT",
+	KeyCsvSchemas:        "STORI_CSV_SCHEMAS",
+	KeyLenient:           "STORI_LENIENT",
+	KeyBatchSize:         "STORI_BATCH_SIZE",
+	KeySummary:           "STORI_SUMMARY_LIMIT",
+	KeyAttachmentLimit:   "STORI_ATTACHMENT_LIMIT",
+	KeyStatementURL:      "STORI_STATEMENT_URL",
+	KeyLogLevel:          "STORI_LOG_LEVEL",
+	KeyLogFormat:         "STORI_LOG_FORMAT",
+	KeySecrets:           "STORI_SECRETS",
+}
+
+// EnvName returns the environment variable of a key.
+func EnvName(key string) string {
+	return envNames[key]
+}
+
+// Section groups the settings a component can not run without.
+type Section string
+
+const (
+	SectionPostgres Section = "postgres"
+	SectionEmail    Section = "email"
+	SectionStorage  Section = "storage"
+	// SectionUploads requires the bucket the http api stores the uploaded files in.
+	SectionUploads Section = "uploads"
+)
+
+var required = map[Section][]string{
+	SectionPostgres: {KeyPgHost, KeyPgDatabase, KeyPgUser, KeyPgPassword},
+	SectionEmail:    {KeyAwsRegion, KeyAwsSesFrom},
+	SectionStorage:  {KeyAwsRegion},
+	SectionUploads:  {KeyAwsRegion, KeyBucket},
+}
+
+// emailRequired replaces the settings of SectionEmail for the transports other than ses.
+var emailRequired = map[string][]string{
+	TransportSMTP: {KeySmtpHost, KeyAwsSesFrom},
+	TransportFile: {KeyEmailDir, KeyAwsSesFrom},
+}
+
+// FieldError describes a missing or invalid setting.
+type FieldError struct {
+	Key    string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", e.Key, EnvName(e.Key), e.Reason)
+}
+
+// ValidationError reports every invalid setting at once.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(key, reason string) {
+	e.Fields = append(e.Fields, FieldError{Key: key, Reason: reason})
+}
+
+// Loader fills the Config from, in increasing precedence, the optional File, the secrets provider
+// named by the secrets key, the environment and the Overrides.
+type Loader struct {
+	// File is a .env file, a missing file is ignored.
+	File string
+	// Overrides usually come from command line flags.
+	Overrides map[string]string
+	// Require lists the sections that must be complete.
+	Require []Section
+
+	lookupEnv func(string) (string, bool)
+	providers func(ref string, aws AwsConfig) (SecretsProvider, error)
+}
+
+// Load reads and validates the config, the error is a *ValidationError when settings are missing or invalid.
+func (l Loader) Load(ctx context.Context) (*Config, error) {
+	if l.lookupEnv == nil {
+		l.lookupEnv = os.LookupEnv
+	}
+	if l.providers == nil {
+		l.providers = NewSecretsProvider
+	}
+
+	values := map[string]string{}
+	if l.File != "" {
+		file, err := godotenv.Read(l.File)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", l.File)
+		}
+		for key, value := range file {
+			values[key] = value
+		}
+	}
+
+	merge := func() {
+		for key, name := range envNames {
+			if value, ok := l.lookupEnv(name); ok {
+				values[key] = value
+			}
+		}
+		for key, value := range l.Overrides {
+			values[key] = value
+		}
+	}
+
+	// the provider and its aws credentials may come from any source
+	merge()
+	if ref := values[KeySecrets]; ref != "" {
+		provider, err := l.providers(ref, awsConfig(values, l.lookupEnv))
+		if err != nil {
+			return nil, err
+		}
+		secrets, err := provider.Secrets(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read secrets")
+		}
+		for key, value := range secrets {
+			values[key] = value
+		}
+		merge()
+	}
+
+	return l.build(values)
+}
+
+// awsConfig falls back to the region the aws runtimes set.
+func awsConfig(values map[string]string, lookupEnv func(string) (string, bool)) AwsConfig {
+	config := AwsConfig{Region: values[KeyAwsRegion], Key: values[KeyAwsKey], Secret: values[KeyAwsSecret]}
+	if config.Region == "" {
+		config.Region, _ = lookupEnv("AWS_REGION")
+	}
+	return config
+}
+
+func (l Loader) build(values map[string]string) (*Config, error) {
+	problems := &ValidationError{}
+	aws := awsConfig(values, l.lookupEnv)
+	if aws.Region != "" {
+		values[KeyAwsRegion] = aws.Region
+	}
+
+	config := &Config{
+		AwsSesConfig: AwsSesConfig{AwsConfig: aws},
+		EmailConfig: EmailConfig{
+			Transport: values[KeyEmailTransport],
+			Smtp: SmtpConfig{
+				Host:     values[KeySmtpHost],
+				User:     values[KeySmtpUser],
+				Password: values[KeySmtpPassword],
+				TLS:      values[KeySmtpTLS],
+			},
+			Dir:    values[KeyEmailDir],
+			From:   values[KeyAwsSesFrom],
+			Locale: values[KeyLocale],
+		},
+		S3Config: S3Config{AwsConfig: aws, Bucket: values[KeyBucket]},
+		PgConfig: PgConfig{
+			Enabled:        values[KeyPgHost] != "",
+			Host:           values[KeyPgHost],
+			User:           values[KeyPgUser],
+			Password:       values[KeyPgPassword],
+			Database:       values[KeyPgDatabase],
+			InsertStrategy: values[KeyPgInsertStrategy],
+		},
+		Logging: LoggingConfig{Level: values[KeyLogLevel], Format: values[KeyLogFormat]},
+	}
+
+	if (aws.Key == "") != (aws.Secret == "") {
+		problems.add(KeyAwsSecret, "awsKey and awsSecret must be set together")
+	}
+
+	format, err := dateparse.ParseFormat(values[KeyDateFormat])
+	if err != nil {
+		problems.add(KeyDateFormat, err.Error())
+	}
+	config.Processing.DateFormat = format
+
+	if path := values[KeyCsvSchemas]; path != "" {
+		config.Processing.Schemas, err = csvschema.LoadRegistry(path)
+		if err != nil {
+			problems.add(KeyCsvSchemas, err.Error())
+		}
+	}
+
+	for _, target := range strings.Split(values[KeyUnsubscribe], ",") {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		if !strings.HasPrefix(target, "mailto:") && !strings.HasPrefix(target, "https://") {
+			problems.add(KeyUnsubscribe, fmt.Sprintf("%q is not a mailto: or https:// target", target))
+			continue
+		}
+		config.EmailConfig.Unsubscribe = append(config.EmailConfig.Unsubscribe, target)
+	}
+
+	if link := values[KeyStatementURL]; link != "" {
+		if !strings.HasPrefix(link, "https://") && !strings.HasPrefix(link, "http://") {
+			problems.add(KeyStatementURL, fmt.Sprintf("%q is not an http(s) link", link))
+		}
+		config.Processing.StatementURL = link
+	}
+
+	switch transport := values[KeyEmailTransport]; transport {
+	case "", TransportSES, TransportSMTP, TransportFile:
+	default:
+		problems.add(KeyEmailTransport, fmt.Sprintf("%q is not ses, smtp or file", transport))
+	}
+	switch mode := values[KeySmtpTLS]; mode {
+	case "", "starttls", "none":
+	default:
+		problems.add(KeySmtpTLS, fmt.Sprintf("%q is not starttls or none", mode))
+	}
+
+	switch level := values[KeyLogLevel]; level {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		problems.add(KeyLogLevel, fmt.Sprintf("%q is not debug, info, warn or error", level))
+	}
+	switch format := values[KeyLogFormat]; format {
+	case "", "json", "text":
+	default:
+		problems.add(KeyLogFormat, fmt.Sprintf("%q is not json or text", format))
+	}
+
+	switch strategy := values[KeyPgInsertStrategy]; strategy {
+	case "", "batch", "copy":
+	default:
+		problems.add(KeyPgInsertStrategy, fmt.Sprintf("%q is not batch or copy", strategy))
+	}
+
+	for _, setting := range []struct {
+		key    string
+		target *bool
+	}{
+		{KeyPgAutoMigrate, &config.PgConfig.AutoMigrate},
+		{KeyLenient, &config.Processing.Lenient},
+	} {
+		value := values[setting.key]
+		if value == "" {
+			continue
+		}
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			problems.add(setting.key, fmt.Sprintf("%q is not a boolean", value))
+			continue
+		}
+		*setting.target = flag
+	}
+
+	for _, setting := range []struct {
+		key    string
+		target *int
+	}{
+		{KeyBatchSize, &config.Processing.BatchSize},
+		{KeySummary, &config.Processing.SummaryLimit},
+		{KeyAttachmentLimit, &config.Processing.AttachmentLimit},
+		{KeyPgInsertBatchSize, &config.PgConfig.InsertBatchSize},
+		{KeyOutboxMaxAttempts, &config.Outbox.MaxAttempts},
+		{KeyOutboxBatchSize, &config.Outbox.BatchSize},
+	} {
+		value := values[setting.key]
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			problems.add(setting.key, fmt.Sprintf("%q is not a positive number", value))
+			continue
+		}
+		*setting.target = number
+	}
+
+	if value := values[KeyOutboxBackoff]; value != "" {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff <= 0 {
+			problems.add(KeyOutboxBackoff, fmt.Sprintf("%q is not a positive duration", value))
+		}
+		config.Outbox.Backoff = backoff
+	}
+
+	missing := map[string]bool{}
+	for _, section := range l.Require {
+		keys := required[section]
+		if transport, ok := emailRequired[config.EmailConfig.Transport]; ok && section == SectionEmail {
+			keys = transport
+		}
+		for _, key := range keys {
+			if strings.TrimSpace(values[key]) == "" && !missing[key] {
+				missing[key] = true
+				problems.add(key, fmt.Sprintf("required by %s", section))
+			}
+		}
+	}
+
+	if len(problems.Fields) > 0 {
+		return nil, problems
+	}
+
+	return config, nil
+}
@@ -0,0 +1,121 @@
+package application
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/dateparse"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	secrets := writeFile(t, "secrets.json", `{"password": "from-secrets", "user": "from-secrets"}`)
+	file := writeFile(t, ".env", "host: localhost:5432\ndatabase: postgres\nuser: from-file\npassword: from-file\n"+
+		"awsRegion: us-east-1\nawsSesFrom: noreply@example.com\ndateFormat: ISO\nsecrets: file://"+secrets+"\n")
+
+	loader := Loader{
+		File:      file,
+		Overrides: map[string]string{KeyLenient: "true"},
+		Require:   []Section{SectionPostgres, SectionEmail},
+		lookupEnv: env(map[string]string{"STORI_PG_USER": "from-env"}),
+	}
+
+	config, err := loader.Load(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, PgConfig{Enabled: true, Host: "localhost:5432", Database: "postgres", User: "from-env", Password: "from-secrets"}, config.PgConfig)
+	assert.Equal(t, AwsSesConfig{AwsConfig: AwsConfig{Region: "us-east-1"}}, config.AwsSesConfig)
+	assert.Equal(t, EmailConfig{From: "noreply@example.com"}, config.EmailConfig)
+	assert.Equal(t, ProcessingConfig{DateFormat: dateparse.ISO, Lenient: true}, config.Processing)
+}
+
+func TestLoadValidation(t *testing.T) {
+	loader := Loader{
+		File:      filepath.Join(t.TempDir(), "missing.env"),
+		Require:   []Section{SectionPostgres, SectionEmail},
+		lookupEnv: env(map[string]string{"STORI_PG_HOST": "localhost:5432", "STORI_PG_INSERT_STRATEGY": "merge", "STORI_UNSUBSCRIBE": "mailto:unsubscribe@stori.com, http://stori.com", "STORI_LENIENT": "maybe", "STORI_STATEMENT_URL": "stori.com/statements/{id}", "STORI_AWS_KEY": "key", "STORI_LOG_LEVEL": "verbose", "AWS_REGION": "us-east-1"}),
+	}
+
+	config, err := loader.Load(context.Background())
+
+	assert.Nil(t, config)
+	validation, ok := err.(*ValidationError)
+	require.True(t, ok, err)
+	var keys []string
+	for _, field := range validation.Fields {
+		keys = append(keys, field.Key)
+	}
+	assert.Equal(t, []string{KeyAwsSecret, KeyUnsubscribe, KeyStatementURL, KeyLogLevel, KeyPgInsertStrategy, KeyLenient, KeyPgDatabase, KeyPgUser, KeyPgPassword, KeyAwsSesFrom}, keys)
+	assert.Contains(t, err.Error(), "database (STORI_PG_DATABASE): required by postgres")
+}
+
+func TestNewSecretsProvider(t *testing.T) {
+	_, err := NewSecretsProvider("vault://stori", AwsConfig{})
+	assert.EqualError(t, err, `unknown secrets provider "vault"`)
+
+	_, err = NewSecretsProvider("stori", AwsConfig{})
+	assert.EqualError(t, err, `invalid secrets reference "stori"`)
+
+	provider, err := NewSecretsProvider("file://"+writeFile(t, "secrets.json", `{"password": "secret", "port": 5432}`), AwsConfig{})
+	require.NoError(t, err)
+	secrets, err := provider.Secrets(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"password": "secret", "port": "5432"}, secrets)
+}
+
+func TestLoadEmailTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		missing []string
+	}{
+		{name: "ses", env: map[string]string{}, missing: []string{KeyAwsRegion, KeyAwsSesFrom}},
+		{name: "smtp", env: map[string]string{"STORI_EMAIL_TRANSPORT": "smtp"}, missing: []string{KeySmtpHost, KeyAwsSesFrom}},
+		{name: "file", env: map[string]string{"STORI_EMAIL_TRANSPORT": "file", "STORI_AWS_SES_FROM": "noreply@stori.com"}, missing: []string{KeyEmailDir}},
+		{name: "unknown", env: map[string]string{"STORI_EMAIL_TRANSPORT": "pigeon", "STORI_SMTP_TLS": "ssl"}, missing: []string{KeyEmailTransport, KeySmtpTLS, KeyAwsRegion, KeyAwsSesFrom}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			loader := Loader{
+				File:      filepath.Join(t.TempDir(), "missing.env"),
+				Require:   []Section{SectionEmail},
+				lookupEnv: env(tc.env),
+			}
+
+			_, err := loader.Load(context.Background())
+
+			validation, ok := err.(*ValidationError)
+			require.True(t, ok, err)
+			var keys []string
+			for _, field := range validation.Fields {
+				keys = append(keys, field.Key)
+			}
+			assert.Equal(t, tc.missing, keys)
+		})
+	}
+
+	loader := Loader{
+		File:    filepath.Join(t.TempDir(), "missing.env"),
+		Require: []Section{SectionEmail},
+		lookupEnv: env(map[string]string{"STORI_EMAIL_TRANSPORT": "smtp", "STORI_SMTP_HOST": "localhost:1025",
+			"STORI_SMTP_USER": "stori", "STORI_SMTP_PASSWORD": "secret", "STORI_AWS_SES_FROM": "noreply@stori.com"}),
+	}
+	config, err := loader.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, EmailConfig{Transport: TransportSMTP, Smtp: SmtpConfig{Host: "localhost:1025", User: "stori", Password: "secret"}, From: "noreply@stori.com"}, config.EmailConfig)
+}
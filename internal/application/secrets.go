@@ -0,0 +1,150 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/pkg/errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// SecretsProvider returns settings keyed as in the config file, e.g. {"password": "..."}.
+type SecretsProvider interface {
+	Secrets(ctx context.Context) (map[string]string, error)
+}
+
+// NewSecretsProvider resolves a provider reference:
+//   - file:///run/secrets/stori.json, a json object
+//   - secretsmanager://stori/prod, a Secrets Manager secret holding a json object
+//   - ssm:///stori/prod, the SSM parameters under the path, named after the last segment
+func NewSecretsProvider(ref string, config AwsConfig) (SecretsProvider, error) {
+	scheme, location, ok := strings.Cut(ref, "://")
+	if !ok || location == "" {
+		return nil, errors.Errorf("invalid secrets reference %q", ref)
+	}
+
+	switch scheme {
+	case "file":
+		return FileSecrets{path: location}, nil
+	case "secretsmanager":
+		sess, err := config.Session()
+		if err != nil {
+			return nil, err
+		}
+		return NewSecretsManager(secretsmanager.New(sess), location), nil
+	case "ssm":
+		sess, err := config.Session()
+		if err != nil {
+			return nil, err
+		}
+		return NewParameterStore(ssm.New(sess), location), nil
+	}
+
+	return nil, errors.Errorf("unknown secrets provider %q", scheme)
+}
+
+// Session builds an aws session, static credentials are only used when both the key and the secret are set.
+func (c AwsConfig) Session() (*session.Session, error) {
+	config := &aws.Config{Region: aws.String(c.Region)}
+	if c.Key != "" && c.Secret != "" {
+		config.Credentials = credentials.NewStaticCredentials(c.Key, c.Secret, "")
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init aws session")
+	}
+
+	return sess, nil
+}
+
+// FileSecrets reads the secrets from a json file, it stands in for the aws providers locally.
+type FileSecrets struct {
+	path string
+}
+
+func (f FileSecrets) Secrets(ctx context.Context) (map[string]string, error) {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSecrets(content)
+}
+
+// decodeSecrets reads a json object, non string values keep their json text.
+func decodeSecrets(content []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, errors.Wrap(err, "secrets must be a json object")
+	}
+
+	secrets := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			text = string(value)
+		}
+		secrets[key] = text
+	}
+
+	return secrets, nil
+}
+
+// SecretsManager reads a secret holding a json object.
+type SecretsManager struct {
+	client secretsmanageriface.SecretsManagerAPI
+	id     string
+}
+
+func NewSecretsManager(client secretsmanageriface.SecretsManagerAPI, id string) *SecretsManager {
+	return &SecretsManager{client: client, id: id}
+}
+
+func (s *SecretsManager) Secrets(ctx context.Context) (map[string]string, error) {
+	output, err := s.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.id),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "secret %s", s.id)
+	}
+
+	return decodeSecrets([]byte(aws.StringValue(output.SecretString)))
+}
+
+// ParameterStore reads the SSM parameters stored under a path, e.g. /stori/prod/password is the password key.
+type ParameterStore struct {
+	client ssmiface.SSMAPI
+	path   string
+}
+
+func NewParameterStore(client ssmiface.SSMAPI, path string) *ParameterStore {
+	return &ParameterStore{client: client, path: path}
+}
+
+func (p *ParameterStore) Secrets(ctx context.Context) (map[string]string, error) {
+	secrets := map[string]string{}
+
+	err := p.client.GetParametersByPathPagesWithContext(ctx, &ssm.GetParametersByPathInput{
+		Path:           aws.String(p.path),
+		WithDecryption: aws.Bool(true),
+	}, func(output *ssm.GetParametersByPathOutput, last bool) bool {
+		for _, parameter := range output.Parameters {
+			secrets[path.Base(aws.StringValue(parameter.Name))] = aws.StringValue(parameter.Value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "parameters %s", p.path)
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,217 @@
+package csvschema
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"stori-challenge/internal/dateparse"
+	"strings"
+)
+
+// Names of the columns understood by the processor.
+const (
+	ColumnID          = "id"
+	ColumnDate        = "date"
+	ColumnAmount      = "amount"
+	ColumnDescription = "description"
+	ColumnMerchant    = "merchant"
+	ColumnCurrency    = "currency"
+	// ColumnType holds debit or credit, amounts of typed files may be written without sign.
+	ColumnType = "type"
+)
+
+// Header tells whether the first row of a file holds the column names.
+type Header string
+
+const (
+	// HeaderAuto treats the first row as a header when any of its cells names a column.
+	HeaderAuto Header = "auto"
+	// HeaderPresent always skips the first row.
+	HeaderPresent Header = "present"
+	// HeaderAbsent maps the columns by position in the order they are declared in the schema.
+	HeaderAbsent Header = "absent"
+)
+
+// DefaultSource is the schema used by files that do not belong to a configured source.
+const DefaultSource = "default"
+
+var (
+	ErrMissingColumns = errors.New("missing required columns")
+	ErrMissingValue   = errors.New("missing required value")
+)
+
+// Column declares a field of the file, Aliases are alternative header names.
+type Column struct {
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases"`
+	Required bool     `json:"required"`
+}
+
+// Schema describes the layout of the files of a source.
+type Schema struct {
+	Header  Header   `json:"header"`
+	Columns []Column `json:"columns"`
+}
+
+// Default is the layout of the original statements (Id,Date,Transaction) plus the optional
+// columns partners usually send.
+func Default() Schema {
+	return Schema{
+		Header: HeaderAuto,
+		Columns: []Column{
+			{Name: ColumnID, Aliases: []string{"transaction_id", "trx_id"}, Required: true},
+			{Name: ColumnDate, Aliases: []string{"transaction_date", "fecha"}, Required: true},
+			{Name: ColumnAmount, Aliases: []string{"transaction", "value", "monto"}, Required: true},
+			{Name: ColumnDescription, Aliases: []string{"detail", "concept", "concepto"}},
+			{Name: ColumnMerchant, Aliases: []string{"payee", "comercio"}},
+			{Name: ColumnCurrency, Aliases: []string{"currency_code", "moneda"}},
+			{Name: ColumnType, Aliases: []string{"transaction_type", "tipo"}},
+		},
+	}
+}
+
+// normalize reduces a header cell to the form used to compare names: lower case, hints removed
+// and words joined by underscores.
+func normalize(name string) string {
+	name = dateparse.HintPattern.ReplaceAllString(name, " ")
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "\ufeff")
+
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_")
+}
+
+func (s Schema) lookup() map[string]string {
+	names := make(map[string]string)
+	for _, column := range s.Columns {
+		names[normalize(column.Name)] = column.Name
+		for _, alias := range column.Aliases {
+			names[normalize(alias)] = column.Name
+		}
+	}
+	return names
+}
+
+// Map builds the column mapping of a file from its first row. It fails when a required column is missing,
+// header cells that do not name any column are reported in Mapping.Unknown.
+func (s Schema) Map(firstRow []string) (*Mapping, error) {
+	mapping := &Mapping{index: make(map[string]int)}
+	names := s.lookup()
+
+	hasHeader := s.Header == HeaderPresent
+	if s.Header == "" || s.Header == HeaderAuto {
+		for _, cell := range firstRow {
+			if _, ok := names[normalize(cell)]; ok {
+				hasHeader = true
+				break
+			}
+		}
+	}
+
+	if hasHeader {
+		mapping.HasHeader = true
+		mapping.Header = firstRow
+		for i, cell := range firstRow {
+			name, ok := names[normalize(cell)]
+			if !ok {
+				mapping.Unknown = append(mapping.Unknown, cell)
+				continue
+			}
+			if _, duplicated := mapping.index[name]; !duplicated {
+				mapping.index[name] = i
+			}
+		}
+	} else {
+		for i, column := range s.Columns {
+			mapping.index[column.Name] = i
+		}
+	}
+
+	var missing []string
+	for _, column := range s.Columns {
+		if _, ok := mapping.index[column.Name]; column.Required && !ok {
+			missing = append(missing, column.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.Wrapf(ErrMissingColumns, "%s", strings.Join(missing, ", "))
+	}
+
+	return mapping, nil
+}
+
+// Mapping locates the columns of a single file.
+type Mapping struct {
+	HasHeader bool
+	Header    []string
+	Unknown   []string
+	index     map[string]int
+}
+
+// Get returns the trimmed value of the column, ok is false when the file does not have the column.
+func (m *Mapping) Get(record []string, column string) (value string, ok bool) {
+	i, ok := m.index[column]
+	if !ok || i >= len(record) {
+		return "", false
+	}
+	return strings.TrimSpace(record[i]), true
+}
+
+// Required returns the value of a column that can not be empty.
+func (m *Mapping) Required(record []string, column string) (string, error) {
+	value, ok := m.Get(record, column)
+	if !ok || value == "" {
+		return "", errors.Wrapf(ErrMissingValue, "column %s", column)
+	}
+	return value, nil
+}
+
+// HeaderOf returns the raw header cell of the column, used to read hints such as the date format.
+func (m *Mapping) HeaderOf(column string) string {
+	i, ok := m.index[column]
+	if !m.HasHeader || !ok {
+		return ""
+	}
+	return m.Header[i]
+}
+
+// Registry holds the schema of every source.
+type Registry map[string]Schema
+
+// For returns the schema of the source falling back to the DefaultSource entry and then to Default.
+func (r Registry) For(source string) Schema {
+	if schema, ok := r[source]; ok && source != "" {
+		return schema
+	}
+	if schema, ok := r[DefaultSource]; ok {
+		return schema
+	}
+	return Default()
+}
+
+// LoadRegistry reads the schemas of every source from a json file keyed by source.
+func LoadRegistry(path string) (Registry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv schemas")
+	}
+
+	registry := Registry{}
+	if err := json.Unmarshal(content, &registry); err != nil {
+		return nil, errors.Wrap(err, "failed to parse csv schemas")
+	}
+
+	for source, schema := range registry {
+		switch schema.Header {
+		case "", HeaderAuto, HeaderPresent, HeaderAbsent:
+		default:
+			return nil, errors.Errorf("csv schema %s: unknown header mode %q", source, schema.Header)
+		}
+		if len(schema.Columns) == 0 {
+			return nil, errors.Errorf("csv schema %s: no columns", source)
+		}
+	}
+
+	return registry, nil
+}
@@ -0,0 +1,98 @@
+package csvschema
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    Schema
+		firstRow  []string
+		record    []string
+		hasHeader bool
+		unknown   []string
+		want      map[string]string
+		err       error
+	}{
+		{
+			name:      "original_header",
+			schema:    Default(),
+			firstRow:  []string{"Id", "Date", "Transaction"},
+			record:    []string{"0", "7/15", "+60.5"},
+			hasHeader: true,
+			want:      map[string]string{ColumnID: "0", ColumnDate: "7/15", ColumnAmount: "+60.5"},
+		},
+		{
+			name:      "reordered_with_aliases",
+			schema:    Default(),
+			firstRow:  []string{"Monto", "Channel", "Fecha (DD/MM/YYYY)", "transaction-id", "Merchant"},
+			record:    []string{"-10.3", "pos", "28/07/2024", "1", "Coffee Shop"},
+			hasHeader: true,
+			unknown:   []string{"Channel"},
+			want:      map[string]string{ColumnID: "1", ColumnDate: "28/07/2024", ColumnAmount: "-10.3", ColumnMerchant: "Coffee Shop"},
+		},
+		{
+			name:     "no_header",
+			schema:   Default(),
+			firstRow: []string{"0", "7/15", "+60.5"},
+			record:   []string{"0", "7/15", "+60.5"},
+			want:     map[string]string{ColumnID: "0", ColumnDate: "7/15", ColumnAmount: "+60.5"},
+		},
+		{
+			name:     "missing_required",
+			schema:   Default(),
+			firstRow: []string{"Id", "Transaction"},
+			err:      ErrMissingColumns,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := tc.schema.Map(tc.firstRow)
+			assert.ErrorIs(t, err, tc.err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, tc.hasHeader, mapping.HasHeader)
+			assert.Equal(t, tc.unknown, mapping.Unknown)
+			for column, want := range tc.want {
+				got, ok := mapping.Get(tc.record, column)
+				assert.True(t, ok, column)
+				assert.Equal(t, want, got, column)
+			}
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	mapping, err := Default().Map([]string{"Id", "Date", "Transaction"})
+	assert.NoError(t, err)
+
+	_, err = mapping.Required([]string{"0", "", "+1"}, ColumnDate)
+	assert.ErrorIs(t, err, ErrMissingValue)
+	assert.Equal(t, "Date", mapping.HeaderOf(ColumnDate))
+}
+
+func TestLoadRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	content := `{"partner-a": {"header": "absent", "columns": [
+		{"name": "date", "required": true},
+		{"name": "amount", "required": true},
+		{"name": "id", "required": true}
+	]}}`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	registry, err := LoadRegistry(path)
+	assert.NoError(t, err)
+
+	mapping, err := registry.For("partner-a").Map([]string{"2024-07-15", "+60.5", "0"})
+	assert.NoError(t, err)
+	id, _ := mapping.Get([]string{"2024-07-15", "+60.5", "0"}, ColumnID)
+	assert.Equal(t, "0", id)
+
+	assert.Equal(t, Default(), registry.For("unknown"))
+}
@@ -0,0 +1,158 @@
+package dateparse
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format identifies the layout of the date column of a file.
+type Format string
+
+const (
+	// ISO is ISO-8601, a calendar date (2024-07-15) or a full timestamp (2024-07-15T10:00:00Z).
+	ISO Format = "ISO-8601"
+	// MonthDay is the legacy M/D layout (7/15), the year is inferred from the statement period.
+	MonthDay Format = "M/D"
+	// MonthDayYear is the US layout M/D/YYYY (7/15/2024).
+	MonthDayYear Format = "M/D/YYYY"
+	// DayMonthYear is DD/MM/YYYY (15/07/2024).
+	DayMonthYear Format = "DD/MM/YYYY"
+)
+
+// Default keeps the layout the csv files were originally sent with.
+const Default = MonthDay
+
+var (
+	ErrUnknownFormat = errors.New("unknown date format")
+	ErrInvalidDate   = errors.New("invalid date")
+)
+
+var aliases = map[string]Format{
+	"iso":        ISO,
+	"iso8601":    ISO,
+	"iso-8601":   ISO,
+	"yyyy-mm-dd": ISO,
+	"m/d":        MonthDay,
+	"mm/dd":      MonthDay,
+	"m/d/yyyy":   MonthDayYear,
+	"mm/dd/yyyy": MonthDayYear,
+	"dd/mm/yyyy": DayMonthYear,
+	"d/m/yyyy":   DayMonthYear,
+}
+
+// HintPattern matches the hint written next to a header name, e.g. "Date (DD/MM/YYYY)" or "Date [ISO]", the
+// first group is the hint.
+var HintPattern = regexp.MustCompile(`[(\[]\s*([^)\]]+?)\s*[)\]]`)
+
+// ParseFormat resolves a format name as written in the config or in a header hint, an empty value is Default.
+func ParseFormat(value string) (Format, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return Default, nil
+	}
+
+	if format, ok := aliases[value]; ok {
+		return format, nil
+	}
+
+	return "", errors.Wrapf(ErrUnknownFormat, "%q", value)
+}
+
+// FromHeader returns the format hinted in a header cell, ok is false when the header has no hint.
+func FromHeader(header string) (format Format, ok bool) {
+	match := HintPattern.FindStringSubmatch(header)
+	if match == nil {
+		return "", false
+	}
+
+	format, err := ParseFormat(match[1])
+	if err != nil {
+		return "", false
+	}
+
+	return format, true
+}
+
+// Parser parses the dates of a single file. periodEnd is the last day covered by the statement,
+// it is used to infer the year of layouts that do not carry one.
+type Parser struct {
+	format    Format
+	periodEnd time.Time
+}
+
+func NewParser(format Format, periodEnd time.Time) *Parser {
+	if format == "" {
+		format = Default
+	}
+
+	return &Parser{format: format, periodEnd: periodEnd}
+}
+
+func (p *Parser) Format() Format {
+	return p.format
+}
+
+// Parse returns the date in UTC.
+func (p *Parser) Parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	switch p.format {
+	case ISO:
+		for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04:05"} {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date.UTC(), nil
+			}
+		}
+	case MonthDayYear:
+		if date, err := time.Parse("1/2/2006", value); err == nil {
+			return date, nil
+		}
+	case DayMonthYear:
+		if date, err := time.Parse("2/1/2006", value); err == nil {
+			return date, nil
+		}
+	case MonthDay:
+		if date, err := time.Parse("1/2", value); err == nil {
+			return p.inferYear(date)
+		}
+	default:
+		return time.Time{}, errors.Wrapf(ErrUnknownFormat, "%q", p.format)
+	}
+
+	return time.Time{}, errors.Wrapf(ErrInvalidDate, "%q is not %s", value, p.format)
+}
+
+// inferYear places a month/day in the latest year that does not go past the end of the statement,
+// so a statement ending in January puts December rows in the previous year.
+func (p *Parser) inferYear(date time.Time) (time.Time, error) {
+	year := p.periodEnd.Year()
+	if date.Month() > p.periodEnd.Month() ||
+		(date.Month() == p.periodEnd.Month() && date.Day() > p.periodEnd.Day()) {
+		year--
+	}
+
+	inferred := time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if inferred.Day() != date.Day() {
+		return time.Time{}, errors.Wrapf(ErrInvalidDate, "%s %d does not exist in %d", date.Month(), date.Day(), year)
+	}
+
+	return inferred, nil
+}
+
+// PeriodEnd reads the end of the statement period from a YYYY-MM-DD or YYYY-MM value,
+// a month stands for its last day.
+func PeriodEnd(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(ErrInvalidDate, "statement period %q", value)
+	}
+
+	return month.AddDate(0, 1, -1), nil
+}
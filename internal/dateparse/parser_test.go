@@ -0,0 +1,70 @@
+package dateparse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	periodEnd := date(2025, time.January, 10)
+
+	tests := []struct {
+		name   string
+		format Format
+		value  string
+		want   time.Time
+		err    error
+	}{
+		{name: "iso", format: ISO, value: "2024-07-15", want: date(2024, time.July, 15)},
+		{name: "iso_timestamp", format: ISO, value: "2024-07-15T00:00:00Z", want: date(2024, time.July, 15)},
+		{name: "month_day_year", format: MonthDayYear, value: "7/15/2024", want: date(2024, time.July, 15)},
+		{name: "day_month_year", format: DayMonthYear, value: "15/07/2024", want: date(2024, time.July, 15)},
+		{name: "month_day_same_year", format: MonthDay, value: "1/5", want: date(2025, time.January, 5)},
+		{name: "month_day_rollover", format: MonthDay, value: "12/28", want: date(2024, time.December, 28)},
+		{name: "month_day_after_period", format: MonthDay, value: "1/11", want: date(2024, time.January, 11)},
+		{name: "month_day_leap_day", format: MonthDay, value: "2/29", want: date(2024, time.February, 29)},
+		{name: "wrong_layout", format: DayMonthYear, value: "7/15/2024", err: ErrInvalidDate},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewParser(tc.format, periodEnd).Parse(tc.value)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFormatSelection(t *testing.T) {
+	format, err := ParseFormat("dd/mm/yyyy")
+	assert.NoError(t, err)
+	assert.Equal(t, DayMonthYear, format)
+
+	format, err = ParseFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, Default, format)
+
+	_, err = ParseFormat("yyyy/dd/mm")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	format, ok := FromHeader("Date (ISO-8601)")
+	assert.True(t, ok)
+	assert.Equal(t, ISO, format)
+
+	_, ok = FromHeader("Date")
+	assert.False(t, ok)
+}
+
+func TestPeriodEnd(t *testing.T) {
+	end, err := PeriodEnd("2024-02")
+	assert.NoError(t, err)
+	assert.Equal(t, date(2024, time.February, 29), end)
+
+	end, err = PeriodEnd("2024-12-15")
+	assert.NoError(t, err)
+	assert.Equal(t, date(2024, time.December, 15), end)
+}
@@ -0,0 +1,197 @@
+package email
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/fs"
+	"path"
+	"stori-challenge/internal/model"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is used when neither the recipient nor the service selects a known locale.
+const DefaultLocale = "en"
+
+// localeFS holds a catalog per locale, <language>.json or <language>-<REGION>.json. A regional catalog
+// only lists what differs from the catalog of its language, e.g. the separators of es-MX.
+//
+//go:embed locales
+var localeFS embed.FS
+
+var locales = mustParseLocales(localeFS)
+
+// Locale formats the amounts, months and dates of an email and translates its messages.
+type Locale struct {
+	Tag string `json:"-"`
+	// Decimal and Group separate the fraction and the thousands of amounts.
+	Decimal string `json:"decimal"`
+	Group   string `json:"group"`
+	// Date is the time layout of dates, Month a format of the month name and the year.
+	Date     string            `json:"date"`
+	Month    string            `json:"month"`
+	Months   []string          `json:"months"`
+	Messages map[string]string `json:"messages"`
+}
+
+// parseLocales reads the catalogs, regional catalogs are completed with the catalog of their language.
+func parseLocales(fsys fs.FS) (map[string]*Locale, error) {
+	files, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := make(map[string]*Locale, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		locale := &Locale{Tag: strings.TrimSuffix(path.Base(file), ".json")}
+		if err := json.Unmarshal(data, locale); err != nil {
+			return nil, errors.Wrapf(err, "invalid catalog %s", file)
+		}
+		catalogs[locale.Tag] = locale
+	}
+
+	for tag, locale := range catalogs {
+		language, _, regional := strings.Cut(tag, "-")
+		if !regional {
+			continue
+		}
+		base, ok := catalogs[language]
+		if !ok {
+			return nil, errors.Errorf("catalog %s needs the catalog of %s", tag, language)
+		}
+		locale.inherit(base)
+	}
+
+	for tag, locale := range catalogs {
+		if locale.Decimal == "" || locale.Date == "" || locale.Month == "" || len(locale.Months) != 12 {
+			return nil, errors.Errorf("catalog %s needs the decimal separator, the date and month formats and 12 months", tag)
+		}
+	}
+
+	return catalogs, nil
+}
+
+func mustParseLocales(fsys fs.FS) map[string]*Locale {
+	catalogs, err := parseLocales(fsys)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to parse the email locales"))
+	}
+	if _, ok := catalogs[DefaultLocale]; !ok {
+		panic(errors.Errorf("the default locale %s has no catalog", DefaultLocale))
+	}
+	return catalogs
+}
+
+// inherit fills what the locale does not define with the values of base.
+func (l *Locale) inherit(base *Locale) {
+	if l.Decimal == "" {
+		l.Decimal, l.Group = base.Decimal, base.Group
+	}
+	if l.Date == "" {
+		l.Date = base.Date
+	}
+	if l.Month == "" {
+		l.Month = base.Month
+	}
+	if len(l.Months) == 0 {
+		l.Months = base.Months
+	}
+	messages := make(map[string]string, len(base.Messages))
+	for key, message := range base.Messages {
+		messages[key] = message
+	}
+	for key, message := range l.Messages {
+		messages[key] = message
+	}
+	l.Messages = messages
+}
+
+// findLocale picks the catalog of a tag such as "es-AR", "es_ar" or "es": the regional catalog, then the
+// catalog of the language and ok is false when none exists.
+func findLocale(tag string) (*Locale, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	language, region, _ := strings.Cut(tag, "-")
+	language = strings.ToLower(language)
+	if region != "" {
+		if locale, ok := locales[language+"-"+strings.ToUpper(region)]; ok {
+			return locale, true
+		}
+	}
+	locale, ok := locales[language]
+	return locale, ok
+}
+
+// selectLocale returns the locale of the first known tag, the default locale otherwise.
+func selectLocale(tags ...string) *Locale {
+	for _, tag := range tags {
+		if locale, ok := findLocale(tag); ok {
+			return locale
+		}
+	}
+	return locales[DefaultLocale]
+}
+
+// Translate formats the message of key with args, a missing message renders as the key so it is noticed.
+func (l *Locale) Translate(key string, args ...any) string {
+	message, ok := l.Messages[key]
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// FormatMoney groups the thousands and uses the decimal separator of the locale, e.g. "1.234,56 USD".
+func (l *Locale) FormatMoney(m model.Money) string {
+	amount, currency, _ := strings.Cut(m.Format(), " ")
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(amount, ".")
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(l.Group)
+		}
+		grouped.WriteRune(digit)
+	}
+	if hasFraction {
+		grouped.WriteString(l.Decimal + fraction)
+	}
+
+	return sign + grouped.String() + " " + currency
+}
+
+// FormatMonth names the month and the year of t, e.g. "julio de 2024".
+func (l *Locale) FormatMonth(t time.Time) string {
+	return fmt.Sprintf(l.Month, l.Months[t.Month()-1], t.Year())
+}
+
+// FormatDate formats a date with the layout of the locale, month names are translated.
+func (l *Locale) FormatDate(t time.Time) string {
+	date := t.Format(l.Date)
+	if strings.Contains(l.Date, "January") {
+		date = strings.Replace(date, t.Month().String(), l.Months[t.Month()-1], 1)
+	}
+	return date
+}
+
+// funcs are the helpers available to the templates rendered in the locale.
+func (l *Locale) funcs() map[string]any {
+	return map[string]any{
+		"t":     l.Translate,
+		"money": l.FormatMoney,
+		"month": func(m model.MonthSummary) string { return l.FormatMonth(m.Start()) },
+		"date":  l.FormatDate,
+	}
+}
@@ -0,0 +1,90 @@
+package email
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"stori-challenge/internal/model"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		locale string
+		amount model.Money
+		want   string
+	}{
+		{"en", model.NewMoney(123456, "USD"), "1,234.56 USD"},
+		{"es", model.NewMoney(123456, "USD"), "1.234,56 USD"},
+		{"es-AR", model.NewMoney(-123456789, "ARS"), "-1.234.567,89 ARS"},
+		{"es-MX", model.NewMoney(123456, "MXN"), "1,234.56 MXN"},
+		{"es", model.NewMoney(5, "USD"), "0,05 USD"},
+		{"es", model.NewMoney(1234567, "JPY"), "1.234.567 JPY"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.locale+" "+tc.want, func(t *testing.T) {
+			assert.Equal(t, tc.want, selectLocale(tc.locale).FormatMoney(tc.amount))
+		})
+	}
+}
+
+func TestSelectLocale(t *testing.T) {
+	for tag, want := range map[string]string{
+		"es-MX": "es-MX",
+		"es_mx": "es-MX",
+		"es-AR": "es",
+		"ES":    "es",
+		"en-GB": "en",
+		"pt-BR": DefaultLocale,
+		"":      DefaultLocale,
+	} {
+		assert.Equal(t, want, selectLocale(tag).Tag, tag)
+	}
+	assert.Equal(t, "es", selectLocale("pt", "es-CO").Tag)
+}
+
+func TestLocaleDates(t *testing.T) {
+	date := time.Date(2024, time.July, 5, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "July 5, 2024", selectLocale("en").FormatDate(date))
+	assert.Equal(t, "5/7/2024", selectLocale("es").FormatDate(date))
+	assert.Equal(t, "05/07/2024", selectLocale("es-MX").FormatDate(date))
+	assert.Equal(t, "July 2024", selectLocale("en").FormatMonth(date))
+	assert.Equal(t, "julio de 2024", selectLocale("es-MX").FormatMonth(date))
+}
+
+// TestCatalogs keeps the catalogs in sync, every language translates every message of the default locale.
+func TestCatalogs(t *testing.T) {
+	for tag, locale := range locales {
+		for key := range locales[DefaultLocale].Messages {
+			assert.Contains(t, locale.Messages, key, tag)
+		}
+	}
+}
+
+func TestParseLocalesRegionNeedsLanguage(t *testing.T) {
+	_, err := parseLocales(fstest.MapFS{"locales/pt-BR.json": {Data: []byte(`{"decimal": ","}`)}})
+
+	assert.EqualError(t, err, "catalog pt-BR needs the catalog of pt")
+}
+
+func TestRenderSummarySpanish(t *testing.T) {
+	html, text, err := renderTemplate(TemplateSummary, selectLocale("es-AR"), model.Data{
+		Name:          "Julieta",
+		EndingBalance: model.NewMoney(123456, "ARS"),
+		Date:          time.Date(2024, time.August, 15, 10, 0, 0, 0, time.UTC),
+		Months:        []model.MonthSummary{{Month: "2024-07", Count: 3, ClosingBalance: model.NewMoney(123456, "ARS")}},
+	})
+
+	require.NoError(t, err)
+	for _, body := range []string{html, text} {
+		assert.Contains(t, body, "Hola Julieta")
+		assert.Contains(t, body, "Procesado el 15/8/2024.")
+		assert.Contains(t, body, "1.234,56 ARS")
+		assert.Contains(t, body, "julio de 2024")
+		assert.NotContains(t, body, "Results")
+	}
+	assert.True(t, strings.Contains(text, "Saldo total: 1.234,56 ARS"), text)
+}
@@ -1,82 +1,68 @@
 package email
 
 import (
-	"bytes"
 	"context"
-	_ "embed"
-	"html/template"
-	"stori-challenge/internal/integrations/aws/ses"
+	"net/url"
+	"stori-challenge/internal/message"
 	"stori-challenge/internal/model"
-	"strconv"
+	"strings"
 )
 
-const (
-	noreplyEmail    = "benitezjulietasol@gmail.com"
-	SubjectEmail    = "CSV summary account information"
-	prefixMonthRow  = "<tr><td align=\"center\" width=\"20%\" style=\"margin: 10px 0 0;font-family: Archivo, Arial, Helvetica, sans-serif;font-style: normal;font-weight: normal;font-size: 14px;line-height: 22px;color: #070715;\">"
-	suffixMonthRow  = "</td>"
-	prefixAmountRow = "<td align=\"center\" width=\"20%\" style=\"margin: 10px 0 0;font-family: Archivo, Arial, Helvetica, sans-serif;font-style: normal;font-weight: normal;font-size: 14px;line-height: 22px;color: #070715;\">"
-	suffixAmountRow = "</td></tr>"
-)
-
-//go:embed template.html
-var TemplateSummary string
-
-type emailService interface {
-	SendEmail(context.Context, ses.SendEmailParams) error
+// Transport delivers the rendered emails, see NewTransport.
+type Transport interface {
+	SendEmail(context.Context, message.Message) error
 }
 
 type Service struct {
-	emailService emailService
+	emailService Transport
+	from         string
+	unsubscribe  []string
+	locale       string
 }
 
-func NewService(emailService emailService) *Service {
-	return &Service{emailService}
-}
-
-// RenderTemplate Helper function to write HTML templates based in payload info
-func renderTemplate(tplStr string, payload interface{}) (string, error) {
-	t := template.New("some_tpl")
-	tpl, err := t.Parse(tplStr)
-
-	if err != nil {
-		//logger.Errorf("failed to parse template %s", err.Error())
-		return "", err
-	}
-
-	var buf bytes.Buffer
-
-	if err := tpl.Execute(&buf, payload); err != nil {
-		//logger.Errorf("failed to parse template %s", err.Error())
-		return "", err
-	}
-
-	return buf.String(), nil
+// NewService builds the email service, from is the sender address of every email (awsSesFrom) and
+// unsubscribe the targets of the List-Unsubscribe header, "{email}" is replaced by the escaped recipient.
+// locale is used for the recipients without a known locale, DefaultLocale when it is not known either.
+func NewService(emailService Transport, from string, unsubscribe []string, locale string) *Service {
+	return &Service{emailService: emailService, from: from, unsubscribe: unsubscribe, locale: locale}
 }
 
+// SendEmail renders the email in the locale of the recipient, the subject defaults to the
+// "<template>.subject" message of the locale.
 func (s *Service) SendEmail(ctx context.Context, params model.EmailParams) error {
-	html, err := renderTemplate(params.Template, params.Payload)
+	locale := selectLocale(params.Locale, s.locale)
+	html, text, err := renderTemplate(params.Template, locale, params.Payload)
 	if err != nil {
 		return err
 	}
 
+	subject := params.Subject
+	if subject == "" {
+		subject = locale.Translate(params.Template + ".subject")
+	}
+
 	return s.emailService.SendEmail(
 		ctx,
-		ses.SendEmailParams{
-			From:    noreplyEmail,
-			To:      params.To,
-			Subject: params.Subject,
-			Html:    html,
+		message.Message{
+			From:            s.from,
+			To:              params.To,
+			Subject:         subject,
+			Text:            text,
+			Html:            html,
+			ListUnsubscribe: s.listUnsubscribe(params.To),
+			Attachments:     params.Attachments,
 		},
 	)
 }
 
-func GenerateMonthlySummary(transactions map[string]int) template.HTML {
-	var html string
-	for month, amount := range transactions {
-		monthRow := prefixMonthRow + month + suffixMonthRow + prefixAmountRow + strconv.Itoa(amount) + suffixAmountRow
-		html = html + monthRow
+func (s *Service) listUnsubscribe(to []string) []string {
+	if len(s.unsubscribe) == 0 || len(to) == 0 {
+		return nil
 	}
 
-	return template.HTML(html)
+	targets := make([]string, len(s.unsubscribe))
+	for i, target := range s.unsubscribe {
+		targets[i] = strings.ReplaceAll(target, "{email}", url.QueryEscape(to[0]))
+	}
+	return targets
 }
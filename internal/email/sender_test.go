@@ -0,0 +1,120 @@
+package email
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"stori-challenge/internal/message"
+	"stori-challenge/internal/model"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderSummary(t *testing.T) {
+	html, text, err := renderTemplate(TemplateSummary, locales[DefaultLocale], model.Data{
+		Name:          "<b>Julieta</b>",
+		EndingBalance: model.NewMoney(3974, "USD"),
+		Months: []model.MonthSummary{
+			{Month: "2023-12", Count: 1, ClosingBalance: model.NewMoney(6050, "USD")},
+			{Month: "2024-01", Count: 3, ClosingBalance: model.NewMoney(3974, "USD")},
+		},
+		StatementURL: "https://stori.com/statements/abc?format=pdf&lang=en",
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, html, "Hi &lt;b&gt;Julieta&lt;/b&gt;")
+	assert.Contains(t, html, "39.74 USD")
+	december, january := strings.Index(html, "December 2023"), strings.Index(html, "January 2024")
+	assert.True(t, december > 0 && december < january, "months are rendered in order")
+
+	assert.True(t, strings.HasPrefix(text, "Hi <b>Julieta</b>\n"), text)
+	assert.Contains(t, text, "Total balance: 39.74 USD")
+	december, january = strings.Index(text, "December 2023"), strings.Index(text, "January 2024")
+	assert.True(t, december > 0 && december < january, "months are rendered in order")
+	assert.NotContains(t, text, "<td")
+	assert.Contains(t, html, `href="https://stori.com/statements/abc?format=pdf&amp;lang=en"`)
+	assert.Contains(t, text, "download it from https://stori.com/statements/abc?format=pdf&lang=en\n")
+
+	html, text, err = renderTemplate(TemplateSummary, locales[DefaultLocale], model.Data{StatementAttached: true})
+	require.NoError(t, err)
+	assert.Contains(t, html, "Your statement is attached as PDF and CSV.")
+	assert.Contains(t, text, "Your statement is attached as PDF and CSV.")
+
+	_, _, err = renderTemplate("missing", locales[DefaultLocale], nil)
+	assert.EqualError(t, err, `unknown email template "missing"`)
+}
+
+// TestParsePages adds a page without touching the go code, it only defines the content of the layout.
+func TestParsePages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/layout.html":        {Data: []byte(`{{define "layout"}}<main>{{block "content" .}}{{end}}</main>{{end}}`)},
+		"templates/layout.txt":         {Data: []byte(`{{define "layout"}}{{block "content" .}}{{end}}{{end}}`)},
+		"templates/pages/a.html":       {Data: []byte(`{{define "content"}}a {{.}}{{end}}`)},
+		"templates/pages/a.txt":        {Data: []byte(`{{define "content"}}a{{end}}`)},
+		"templates/pages/welcome.html": {Data: []byte(`{{define "content"}}welcome {{.}}{{end}}`)},
+		"templates/pages/welcome.txt":  {Data: []byte(`{{define "content"}}welcome{{end}}`)},
+	}
+
+	pages, err := parsePages(fsys)
+	require.NoError(t, err)
+
+	for name, expected := range map[string]string{"a": "<main>a x</main>", "welcome": "<main>welcome x</main>"} {
+		var html strings.Builder
+		require.NoError(t, pages[name].html.ExecuteTemplate(&html, "layout", "x"))
+		assert.Equal(t, expected, html.String())
+	}
+}
+
+func TestParsePagesNeedsText(t *testing.T) {
+	_, err := parsePages(fstest.MapFS{
+		"templates/layout.html":  {Data: []byte(`{{define "layout"}}{{end}}`)},
+		"templates/layout.txt":   {Data: []byte(`{{define "layout"}}{{end}}`)},
+		"templates/pages/a.html": {Data: []byte(`{{define "content"}}a{{end}}`)},
+	})
+
+	assert.ErrorContains(t, err, "templates/pages/a.html needs a text alternative")
+}
+
+type recordingSender struct {
+	sent []message.Message
+}
+
+func (r *recordingSender) SendEmail(_ context.Context, params message.Message) error {
+	r.sent = append(r.sent, params)
+	return nil
+}
+
+func TestSendEmail(t *testing.T) {
+	sender := &recordingSender{}
+	service := NewService(sender, "noreply@stori.com", []string{"mailto:unsubscribe@stori.com", "https://stori.com/unsubscribe?email={email}"}, "es-MX")
+
+	err := service.SendEmail(context.Background(), model.EmailParams{
+		To:       []string{"julieta+test@example.com"},
+		Template: TemplateSummary,
+		Payload:  model.Data{Name: "Julieta"},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 1)
+	sent := sender.sent[0]
+	assert.Equal(t, "noreply@stori.com", sent.From)
+	assert.Equal(t, "El resumen de tu cuenta", sent.Subject)
+	assert.Contains(t, sent.Html, "Hola Julieta")
+	assert.Contains(t, sent.Text, "Hola Julieta")
+	assert.Equal(t, []string{"mailto:unsubscribe@stori.com", "https://stori.com/unsubscribe?email=julieta%2Btest%40example.com"}, sent.ListUnsubscribe)
+
+	// the locale of the recipient wins over the default of the service
+	err = service.SendEmail(context.Background(), model.EmailParams{
+		To:       []string{"julieta@example.com"},
+		Subject:  "Statement",
+		Template: TemplateSummary,
+		Locale:   "en_US",
+		Payload:  model.Data{Name: "Julieta"},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, sender.sent, 2)
+	assert.Equal(t, "Statement", sender.sent[1].Subject)
+	assert.Contains(t, sender.sent[1].Text, "Hi Julieta")
+}
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"github.com/pkg/errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// TemplateSummary is the page of the statement summary email.
+const TemplateSummary = "summary"
+
+// templateFS holds the partials shared by every email (layout, header, footer, sections) in templates/
+// and the pages in templates/pages/. Every email is a pair of pages, <name>.html and <name>.txt, that
+// define the "content" block of the html and the text layout. Texts come from the catalog of the locale,
+// a page that needs a different structure in a locale is overridden by <name>.<locale>.html and .txt.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var pages = mustParsePages(templateFS)
+
+// templateFuncs declares the helpers of the templates while parsing, every render binds them to its locale.
+var templateFuncs = (&Locale{}).funcs()
+
+type page struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// parsePages parses the partials once and clones them for every page, so pages can define the same blocks.
+func parsePages(fsys fs.FS) (map[string]page, error) {
+	htmlPartials, err := htmltemplate.New("").Funcs(templateFuncs).ParseFS(fsys, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	textPartials, err := texttemplate.New("").Funcs(templateFuncs).ParseFS(fsys, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(fsys, "templates/pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]page, len(files))
+	for _, file := range files {
+		var p page
+		if p.html, err = htmlPartials.Clone(); err != nil {
+			return nil, err
+		}
+		if p.html, err = p.html.ParseFS(fsys, file); err != nil {
+			return nil, err
+		}
+
+		textFile := strings.TrimSuffix(file, ".html") + ".txt"
+		if p.text, err = textPartials.Clone(); err != nil {
+			return nil, err
+		}
+		if p.text, err = p.text.ParseFS(fsys, textFile); err != nil {
+			return nil, errors.Wrapf(err, "%s needs a text alternative", file)
+		}
+
+		pages[strings.TrimSuffix(path.Base(file), ".html")] = p
+	}
+
+	return pages, nil
+}
+
+func mustParsePages(fsys fs.FS) map[string]page {
+	pages, err := parsePages(fsys)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to parse the email templates"))
+	}
+	return pages
+}
+
+// renderTemplate renders the html and the text alternative of the page with the payload inside the layouts,
+// in the locale and with its page override when there is one.
+func renderTemplate(name string, locale *Locale, payload interface{}) (html, text string, err error) {
+	p, ok := findPage(name, locale.Tag)
+	if !ok {
+		return "", "", errors.Errorf("unknown email template %q", name)
+	}
+
+	// the parsed pages are never executed, html/template can not clone a template once executed
+	htmlPage, err := p.html.Clone()
+	if err != nil {
+		return "", "", err
+	}
+	textPage, err := p.text.Clone()
+	if err != nil {
+		return "", "", err
+	}
+
+	var htmlBuf, textBuf bytes.Buffer
+	if err := htmlPage.Funcs(locale.funcs()).ExecuteTemplate(&htmlBuf, "layout", payload); err != nil {
+		return "", "", errors.Wrapf(err, "failed to render %s", name)
+	}
+	if err := textPage.Funcs(locale.funcs()).ExecuteTemplate(&textBuf, "layout", payload); err != nil {
+		return "", "", errors.Wrapf(err, "failed to render the text of %s", name)
+	}
+
+	return htmlBuf.String(), textBuf.String(), nil
+}
+
+// findPage looks for the page of the locale, of its language and the page shared by every locale.
+func findPage(name, tag string) (page, bool) {
+	language, _, _ := strings.Cut(tag, "-")
+	for _, candidate := range []string{name + "." + tag, name + "." + language, name} {
+		if p, ok := pages[candidate]; ok {
+			return p, true
+		}
+	}
+	return page{}, false
+}
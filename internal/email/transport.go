@@ -0,0 +1,32 @@
+package email
+
+import (
+	"github.com/pkg/errors"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/integrations/aws/ses"
+	"stori-challenge/internal/integrations/filesystem"
+	"stori-challenge/internal/integrations/smtp"
+)
+
+// NewTransport builds the transport selected by the emailTransport setting, ses when it is not set.
+func NewTransport(config *application.Config) (Transport, error) {
+	from := config.EmailConfig.From
+
+	switch config.EmailConfig.Transport {
+	case "", application.TransportSES:
+		service, err := ses.NewService(config.AwsSesConfig, from)
+		if err != nil {
+			return nil, err
+		}
+		return service, nil
+	case application.TransportSMTP:
+		service, err := smtp.NewService(config.EmailConfig.Smtp, from)
+		if err != nil {
+			return nil, err
+		}
+		return service, nil
+	case application.TransportFile:
+		return filesystem.NewMailbox(config.EmailConfig.Dir, from), nil
+	}
+	return nil, errors.Errorf("unknown email transport %q", config.EmailConfig.Transport)
+}
@@ -1,67 +1,82 @@
 package s3
 
 import (
+	"bytes"
 	"context"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/pkg/errors"
-)
-
-const (
-	REGION    = "awsRegion"
-	KEY       = "awsKey"
-	SECRET    = "awsSecret"
-	BUCKET    = "awsBucket"
-	BUCKETKEY = "awsBucketKey"
+	"io"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/model"
+	"strings"
 )
 
 type S3Service struct {
-	region     string
-	client     *s3.S3
-	uploader   *s3manager.Uploader
-	downloader *s3manager.Downloader
-	bucket     string
-	key        string
+	region   string
+	client   *s3.S3
+	uploader *s3manager.Uploader
 }
 
-func NewS3Service(config map[string]string) *S3Service {
-	region := config[REGION]
-	sess, err := session.NewSession(&aws.Config{
-		Region: &(region),
-		Credentials: credentials.NewStaticCredentials(
-			config[KEY],
-			config[SECRET],
-			"",
-		),
-	})
-
+func NewS3Service(config application.S3Config) (*S3Service, error) {
+	sess, err := config.Session()
 	if err != nil {
-		panic(errors.Wrap(err, "failed to init aws session for ecs"))
+		return nil, errors.Wrap(err, "failed to init aws session for s3")
 	}
 
 	return &S3Service{
-		region:     region,
-		client:     s3.New(sess),
-		uploader:   s3manager.NewUploader(sess),
-		downloader: s3manager.NewDownloader(sess),
-		bucket:     config[BUCKET],
-		key:        config[BUCKETKEY],
+		region:   config.Region,
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+// OpenFile streams the object stored under key in the given bucket, the caller closes the body.
+func (s *S3Service) OpenFile(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return output.Body, nil
+}
+
+// WriteFile uploads body under key in the given bucket, metadata is stored as x-amz-meta-* and may be nil.
+func (s *S3Service) WriteFile(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) error {
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:   &bucket,
+		Key:      &key,
+		Body:     bytes.NewReader(body),
+		Metadata: aws.StringMap(metadata),
+	})
+
+	return err
 }
 
-func (s *S3Service) ReadFile(ctx context.Context) ([]byte, error) {
-	buf := aws.NewWriteAtBuffer([]byte{})
-	_, err := s.downloader.DownloadWithContext(ctx, buf, &s3.GetObjectInput{
-		Bucket: &s.bucket,
-		Key:    &s.key,
+// Stat returns the etag and the user defined metadata (x-amz-meta-*) of the object, metadata names are lowercase.
+func (s *S3Service) Stat(ctx context.Context, bucket, key string) (*model.FileInfo, error) {
+	output, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	metadata := make(map[string]string, len(output.Metadata))
+	for name, value := range output.Metadata {
+		metadata[strings.ToLower(name)] = aws.StringValue(value)
+	}
+
+	return &model.FileInfo{
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     strings.Trim(aws.StringValue(output.ETag), `"`),
+		Metadata: metadata,
+	}, nil
 }
@@ -3,11 +3,12 @@ package ses
 import (
 	"context"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sesv2"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/message"
 )
 
 type Service struct {
@@ -15,77 +16,38 @@ type Service struct {
 	defaultFrom string
 }
 
-type SendEmailParams struct {
-	From    string
-	To      []string
-	Subject string
-	Text    string
-	Html    string
-}
-
-func NewService(config map[string]string) *Service {
-	region := config["awsRegion"]
-	sess, err := session.NewSession(&aws.Config{
-		Region: &(region),
-		Credentials: credentials.NewStaticCredentials(
-			config["awsKey"],
-			config["awsSecret"],
-			"",
-		),
-	})
-
+// NewService builds the ses transport, from is the sender of the emails that do not name one.
+func NewService(config application.AwsSesConfig, from string) (*Service, error) {
+	sess, err := config.Session()
 	if err != nil {
-		panic(errors.Wrap(err, "failed to init aws session for ses"))
+		return nil, errors.Wrap(err, "failed to init aws session for ses")
 	}
 
-	return &Service{ses: sesv2.New(sess), defaultFrom: config["awsSesFrom"]}
+	return &Service{ses: sesv2.New(sess), defaultFrom: from}, nil
 }
 
-func (s *Service) SendEmail(ctx context.Context, details SendEmailParams) error {
-	charset := "UTF-8"
-	body := sesv2.Body{}
-	to := make([]*string, len(details.To))
-
-	for i := range details.To {
-		to[i] = &details.To[i]
+// SendEmail sends the email as a raw MIME message, see message.Message.
+func (s *Service) SendEmail(ctx context.Context, details message.Message) error {
+	if details.From == "" {
+		details.From = s.defaultFrom
 	}
 
-	if len(details.Html) > 0 {
-		body.Html = &sesv2.Content{
-			Charset: &charset,
-			Data:    &details.Html,
-		}
-	} else {
-		body.Text = &sesv2.Content{
-			Charset: &charset,
-			Data:    &details.Text,
-		}
+	raw, err := details.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "failed to build the email")
 	}
 
-	from := details.From
-
-	if from == "" {
-		from = s.defaultFrom
-	}
+	log.WithContext(ctx).Infof("Sending email to: %v", logging.RedactEmails(details.To))
 
-	_, err := s.ses.SendEmail(&sesv2.SendEmailInput{
-		FromEmailAddress: &from,
+	_, err = s.ses.SendEmailWithContext(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(details.From),
 		Destination: &sesv2.Destination{
-			ToAddresses: to,
+			ToAddresses: aws.StringSlice(details.To),
 		},
 		Content: &sesv2.EmailContent{
-			Simple: &sesv2.Message{
-				Body: &body,
-				Subject: &sesv2.Content{
-					Charset: &charset,
-					Data:    &details.Subject,
-				},
-			},
+			Raw: &sesv2.RawMessage{Data: raw},
 		},
 	})
-
-	log.WithContext(ctx).Infof("Sending email to: %v", to)
-
 	if err != nil {
 		log.WithContext(ctx).Errorf("failed to send email via aws ses %s", err.Error())
 		return err
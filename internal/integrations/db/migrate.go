@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationsLock is the key of the advisory lock that serializes migrators running at the same time,
+// e.g. several lambdas starting after a deploy.
+const migrationsLock = 7_271_001
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    integer                   not null primary key,
+	name       varchar                   not null,
+	applied_at timestamptz default now() not null
+)`
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change of the schema, Up applies it and Down reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus tells whether a migration was applied, AppliedAt is zero when it is pending.
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// LoadMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql files of fsys ordered by version,
+// every version needs both files.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, errors.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, errors.Errorf("migration %s needs an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies the migrations and records them in schema_migrations. Every migration runs in its
+// own transaction together with its record, so a failing one leaves the schema at the previous version.
+type Migrator struct {
+	db         *pg.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *pg.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load migrations")
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// MigrateUp applies the pending migrations of fsys, it runs when a service starts with autoMigrate.
+func MigrateUp(ctx context.Context, db *pg.DB, fsys fs.FS) error {
+	migrator, err := NewMigrator(db, fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+	return errors.Wrap(err, "failed to migrate the database")
+}
+
+// Up applies the pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	for {
+		var next *Migration
+		err := m.step(ctx, func(tx *pg.Tx, applied map[int]time.Time) error {
+			for i := range m.migrations {
+				if _, ok := applied[m.migrations[i].Version]; !ok {
+					next = &m.migrations[i]
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+
+			if _, err := tx.ExecContext(ctx, next.Up); err != nil {
+				return errors.Wrapf(err, "migration %s failed", next)
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, next.Version, next.Name)
+			return err
+		})
+		if err != nil || next == nil {
+			return done, err
+		}
+
+		log.WithContext(ctx).WithFields(log.Fields{"event": "migrate_up", "migration": next.String()}).Info("migration applied")
+		done = append(done, *next)
+	}
+}
+
+// Down reverts the last steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	for ; steps > 0; steps-- {
+		var last *Migration
+		err := m.step(ctx, func(tx *pg.Tx, applied map[int]time.Time) error {
+			for i := len(m.migrations) - 1; i >= 0; i-- {
+				if _, ok := applied[m.migrations[i].Version]; ok {
+					last = &m.migrations[i]
+					break
+				}
+			}
+			if last == nil {
+				return nil
+			}
+
+			if _, err := tx.ExecContext(ctx, last.Down); err != nil {
+				return errors.Wrapf(err, "migration %s failed to revert", last)
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, last.Version)
+			return err
+		})
+		if err != nil || last == nil {
+			return done, err
+		}
+
+		log.WithContext(ctx).WithFields(log.Fields{"event": "migrate_down", "migration": last.String()}).Info("migration reverted")
+		done = append(done, *last)
+	}
+
+	return done, nil
+}
+
+// Status lists every known migration in order with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.step(ctx, func(tx *pg.Tx, applied map[int]time.Time) error {
+		for _, migration := range m.migrations {
+			statuses = append(statuses, MigrationStatus{Migration: migration, AppliedAt: applied[migration.Version]})
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// step runs fn in a transaction holding the migrations lock, with the versions applied so far.
+func (m *Migrator) step(ctx context.Context, fn func(tx *pg.Tx, applied map[int]time.Time) error) error {
+	return m.db.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(?)`, migrationsLock); err != nil {
+			return errors.Wrap(err, "failed to lock the migrations")
+		}
+		if _, err := tx.ExecContext(ctx, createMigrationsTable); err != nil {
+			return errors.Wrap(err, "failed to create schema_migrations")
+		}
+
+		var rows []struct {
+			Version   int
+			AppliedAt time.Time
+		}
+		if _, err := tx.QueryContext(ctx, &rows, `SELECT version, applied_at FROM schema_migrations`); err != nil {
+			return errors.Wrap(err, "failed to read schema_migrations")
+		}
+
+		applied := make(map[int]time.Time, len(rows))
+		for _, row := range rows {
+			applied[row.Version] = row.AppliedAt
+		}
+
+		return fn(tx, applied)
+	})
+}
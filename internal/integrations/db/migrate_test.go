@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"stori-challenge/pg_migrations"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		expected []Migration
+		err      string
+	}{
+		{
+			name: "ordered_by_version",
+			files: fstest.MapFS{
+				"0010_accounts.up.sql":   {Data: []byte("up 10")},
+				"0010_accounts.down.sql": {Data: []byte("down 10")},
+				"0002_dates.up.sql":      {Data: []byte("up 2")},
+				"0002_dates.down.sql":    {Data: []byte("down 2")},
+				"README.md":              {Data: []byte("ignored")},
+			},
+			expected: []Migration{
+				{Version: 2, Name: "dates", Up: "up 2", Down: "down 2"},
+				{Version: 10, Name: "accounts", Up: "up 10", Down: "down 10"},
+			},
+		},
+		{
+			name:  "missing_down",
+			files: fstest.MapFS{"0001_baseline.up.sql": {Data: []byte("up")}},
+			err:   "migration 0001_baseline needs an up and a down file",
+		},
+		{
+			name: "mismatched_names",
+			files: fstest.MapFS{
+				"0001_baseline.up.sql":  {Data: []byte("up")},
+				"0001_initial.down.sql": {Data: []byte("down")},
+			},
+			err: "migration 1 is named both baseline and initial",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(test.files)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, migrations)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(pgmigrations.FS)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, "0001_baseline", migrations[0].String())
+}
+
+// legacySchema is the table created by the former setup.sql, before the versioned migrations.
+const legacySchema = `create table transactions
+(
+    id         integer                 not null,
+    amount     float8                 not null,
+    date       varchar                 not null,
+    created_at timestamp default now() not null,
+    primary key (id, created_at)
+)`
+
+// TestMigrateUpLegacySchema upgrades a database created with the former setup.sql against the postgres
+// configured through STORI_PG_HOST, STORI_PG_DATABASE, STORI_PG_USER and STORI_PG_PASSWORD.
+func TestMigrateUpLegacySchema(t *testing.T) {
+	host := os.Getenv("STORI_PG_HOST")
+	if host == "" {
+		t.Skip("STORI_PG_HOST is not set")
+	}
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	options := &pg.Options{
+		Addr:     host,
+		Database: os.Getenv("STORI_PG_DATABASE"),
+		User:     os.Getenv("STORI_PG_USER"),
+		Password: os.Getenv("STORI_PG_PASSWORD"),
+	}
+
+	admin := pg.Connect(options)
+	defer admin.Close()
+	_, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	defer admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE")
+
+	options.OnConnect = func(ctx context.Context, conn *pg.Conn) error {
+		_, err := conn.ExecContext(ctx, "SET search_path TO "+schema)
+		return err
+	}
+	database := pg.Connect(options)
+	defer database.Close()
+
+	_, err = database.ExecContext(ctx, legacySchema)
+	require.NoError(t, err)
+	// the former lambda stored M/D dates as year 0000 timestamps and inserted the rows again on every run
+	_, err = database.ExecContext(ctx, `INSERT INTO transactions (id, amount, date, created_at) VALUES
+		(0, 60.5, '0000-07-15 00:00:00+00:00:00', '2024-08-01 10:00:00'),
+		(1, -10.3, '0000-07-28 00:00:00+00:00:00', '2024-08-01 10:00:00'),
+		(1, -10.3, '0000-07-28 00:00:00+00:00:00', '2024-08-02 10:00:00')`)
+	require.NoError(t, err)
+
+	require.NoError(t, MigrateUp(ctx, database, pgmigrations.FS))
+
+	var rows []struct {
+		AccountID string
+		ID        int
+		Amount    string
+		Date      string
+		Type      string
+	}
+	_, err = database.QueryContext(ctx, &rows,
+		`SELECT account_id, id, amount::text, to_char(date, 'YYYY-MM-DD') AS date, type FROM transactions ORDER BY id`)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "legacy", rows[0].AccountID)
+	assert.Equal(t, "60.5", rows[0].Amount)
+	assert.Equal(t, "2024-07-15", rows[0].Date)
+	assert.Equal(t, "credit", rows[0].Type)
+	assert.Equal(t, "-10.3", rows[1].Amount)
+	assert.Equal(t, "debit", rows[1].Type)
+
+	migrator, err := NewMigrator(database, pgmigrations.FS)
+	require.NoError(t, err)
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.AppliedAt.IsZero(), status.String())
+	}
+
+	// the same file uploaded for two accounts no longer fits the checksum key of 0007
+	_, err = database.ExecContext(ctx, `INSERT INTO accounts (id, name, email) VALUES ('42', 'Julieta', 'julieta@example.com');
+		INSERT INTO processed_files (checksum, bucket, key, account_id, processed_at) VALUES
+		('abc', 'stori', 'legacy.csv', 'legacy', '2024-08-01 10:00:00'),
+		('abc', 'stori', '42.csv', '42', '2024-08-02 10:00:00')`)
+	require.NoError(t, err)
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	var accounts []string
+	_, err = database.QueryContext(ctx, &accounts, `SELECT account_id FROM processed_files`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy"}, accounts)
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+)
+
+// UnitOfWork runs a function inside a postgres transaction. The transaction is stored in the context
+// under TransactionKey, so every repository that resolves its connection with GetConnection joins it.
+type UnitOfWork struct {
+	db *pg.DB
+}
+
+func NewUnitOfWork(db *pg.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Run commits when fn succeeds and rolls back on any error or panic. A context that already carries
+// a transaction is reused, so nested units of work commit together with the outer one.
+func (u *UnitOfWork) Run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(TransactionKey).(*pg.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := u.db.BeginContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.RollbackContext(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, TransactionKey, tx)); err != nil {
+		if rollbackErr := tx.RollbackContext(ctx); rollbackErr != nil {
+			return errors.Wrapf(err, "rollback failed: %s", rollbackErr.Error())
+		}
+		return err
+	}
+
+	if err := tx.CommitContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	return nil
+}
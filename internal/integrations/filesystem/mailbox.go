@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/message"
+	"time"
+)
+
+// Mailbox is the email transport of local runs and CI: every email is written to the directory as an .eml
+// file that mail clients open as is.
+type Mailbox struct {
+	dir         string
+	defaultFrom string
+	now         func() time.Time
+}
+
+// NewMailbox builds the file transport, from is the sender of the emails that do not name one.
+func NewMailbox(dir, from string) *Mailbox {
+	return &Mailbox{dir: dir, defaultFrom: from, now: time.Now}
+}
+
+// SendEmail writes the email to <dir>/<time>-<random>.eml, the file is renamed into place once complete
+// so readers of the directory never see a partial email.
+func (o *Mailbox) SendEmail(ctx context.Context, details message.Message) error {
+	if details.From == "" {
+		details.From = o.defaultFrom
+	}
+	raw, err := details.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "failed to build the email")
+	}
+
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := filepath.Join(o.dir, o.now().UTC().Format("20060102T150405.000000000Z")+"-"+hex.EncodeToString(suffix)+".eml")
+
+	temporary, err := os.CreateTemp(o.dir, ".mailbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(raw); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), name); err != nil {
+		return err
+	}
+
+	log.WithContext(ctx).Infof("Email to %v written to %s", logging.RedactEmails(details.To), name)
+	return nil
+}
@@ -0,0 +1,36 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/message"
+	"testing"
+)
+
+func TestMailbox(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "emails")
+	mailbox := NewMailbox(dir, "noreply@stori.com")
+
+	for i := 0; i < 2; i++ {
+		err := mailbox.SendEmail(context.Background(), message.Message{To: []string{"julieta@example.com"}, Subject: "Statement", Text: "hi"})
+		require.NoError(t, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		assert.Equal(t, ".eml", filepath.Ext(file))
+		content, err := os.Open(file)
+		require.NoError(t, err)
+		msg, err := mail.ReadMessage(content)
+		content.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "<noreply@stori.com>", msg.Header.Get("From"))
+		assert.Equal(t, "Statement", msg.Header.Get("Subject"))
+	}
+}
@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"stori-challenge/internal/model"
+	"sync"
+)
+
+// Service is the local stand-in of the s3 service: buckets are directories and keys are paths relative to them.
+// Object metadata can not be stored on disk, the metadata given when the service is built applies to every file
+// and the metadata of the files written by the service is kept in memory.
+type Service struct {
+	metadata map[string]string
+	readOnly bool
+
+	mu      sync.RWMutex
+	written map[string]map[string]string
+}
+
+// NewService builds the filesystem storage, a read only service discards the files it is asked to write.
+func NewService(metadata map[string]string, readOnly bool) *Service {
+	return &Service{metadata: metadata, readOnly: readOnly, written: map[string]map[string]string{}}
+}
+
+func path(bucket, key string) string {
+	return filepath.Join(bucket, filepath.FromSlash(key))
+}
+
+// OpenFile opens the file stored under key in the bucket directory.
+func (s *Service) OpenFile(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return os.Open(path(bucket, key))
+}
+
+// WriteFile writes body under key in the bucket directory.
+func (s *Service) WriteFile(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) error {
+	if s.readOnly {
+		return nil
+	}
+
+	name := path(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name, body, 0o644); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written[name] = metadata
+
+	return nil
+}
+
+// Stat checks the file exists and returns its metadata, files have no etag.
+func (s *Service) Stat(ctx context.Context, bucket, key string) (*model.FileInfo, error) {
+	name := path(bucket, key)
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.Errorf("%s is a directory", name)
+	}
+
+	metadata := make(map[string]string, len(s.metadata))
+	for field, value := range s.metadata {
+		metadata[field] = value
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for field, value := range s.written[name] {
+		metadata[field] = value
+	}
+
+	return &model.FileInfo{Bucket: bucket, Key: key, Metadata: metadata}, nil
+}
@@ -0,0 +1,139 @@
+// Package smtp sends the emails through an SMTP server, e.g. MailHog locally or the relay of a CI environment.
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/mail"
+	gosmtp "net/smtp"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/message"
+	"time"
+)
+
+// TLS modes of application.SmtpConfig.
+const (
+	TLSStartTLS = "starttls"
+	TLSNone     = "none"
+)
+
+// dialTimeout bounds the connection when the context has no deadline.
+const dialTimeout = 30 * time.Second
+
+type Service struct {
+	config      application.SmtpConfig
+	defaultFrom string
+	// tlsConfig is overridden by the tests to trust their server.
+	tlsConfig *tls.Config
+}
+
+// NewService builds the smtp transport, from is the sender of the emails that do not name one.
+func NewService(config application.SmtpConfig, from string) (*Service, error) {
+	host, _, err := net.SplitHostPort(config.Host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid smtp host %q", config.Host)
+	}
+
+	return &Service{config: config, defaultFrom: from, tlsConfig: &tls.Config{ServerName: host}}, nil
+}
+
+// SendEmail delivers the email in a single SMTP session, the connection is upgraded with STARTTLS when the
+// server offers it unless the TLS mode is "none" and authentication uses PLAIN, which net/smtp only allows
+// over TLS or to localhost.
+func (s *Service) SendEmail(ctx context.Context, details message.Message) error {
+	if details.From == "" {
+		details.From = s.defaultFrom
+	}
+	raw, err := details.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "failed to build the email")
+	}
+
+	sender, err := mail.ParseAddress(details.From)
+	if err != nil {
+		return errors.Wrapf(err, "invalid from %q", details.From)
+	}
+	recipients := make([]string, len(details.To))
+	for i, to := range details.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return errors.Wrapf(err, "invalid recipient %d", i+1)
+		}
+		recipients[i] = address.Address
+	}
+
+	log.WithContext(ctx).Infof("Sending email to: %v", logging.RedactEmails(details.To))
+
+	if err := s.send(ctx, sender.Address, recipients, raw); err != nil {
+		log.WithContext(ctx).Errorf("failed to send email via smtp %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, from string, to []string, raw []byte) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.config.Host)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the smtp server")
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := gosmtp.NewClient(conn, s.tlsConfig.ServerName)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "smtp greeting")
+	}
+	defer client.Close()
+
+	if s.config.TLS != TLSNone {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(s.tlsConfig); err != nil {
+				return errors.Wrap(err, "smtp starttls")
+			}
+		} else if s.config.TLS == TLSStartTLS {
+			return errors.New("the smtp server does not offer STARTTLS")
+		}
+	}
+
+	if s.config.User != "" {
+		auth := gosmtp.PlainAuth("", s.config.User, s.config.Password, s.tlsConfig.ServerName)
+		if err := client.Auth(auth); err != nil {
+			return errors.Wrap(err, "smtp auth")
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return errors.Wrap(err, "smtp mail from")
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return errors.Wrapf(err, "smtp rcpt to %s", recipient)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,106 @@
+package smtp
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/textproto"
+	"stori-challenge/internal/application"
+	"stori-challenge/internal/message"
+	"strings"
+	"testing"
+)
+
+// session is what the fake server received.
+type session struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serve runs a MailHog-like server without STARTTLS that accepts a single session.
+func serve(t *testing.T) (string, <-chan session) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan session, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var received session
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command, argument, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO":
+				_ = text.PrintfLine("250-localhost\r\n250-AUTH PLAIN\r\n250 8BITMIME")
+			case "AUTH":
+				credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(argument, "PLAIN "))
+				received.auth = string(credentials)
+				_ = text.PrintfLine("235 authenticated")
+			case "MAIL":
+				received.from = argument
+				_ = text.PrintfLine("250 ok")
+			case "RCPT":
+				received.to = append(received.to, argument)
+				_ = text.PrintfLine("250 ok")
+			case "DATA":
+				_ = text.PrintfLine("354 go ahead")
+				data, _ := text.ReadDotBytes()
+				received.data = string(data)
+				_ = text.PrintfLine("250 queued")
+			case "QUIT":
+				_ = text.PrintfLine("221 bye")
+				sessions <- received
+				return
+			default:
+				_ = text.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), sessions
+}
+
+func TestSendEmail(t *testing.T) {
+	host, sessions := serve(t)
+	service, err := NewService(application.SmtpConfig{Host: host, User: "stori", Password: "secret"}, "Stori <noreply@stori.com>")
+	require.NoError(t, err)
+
+	err = service.SendEmail(context.Background(), message.Message{
+		To:      []string{"Julieta <julieta@example.com>", "ops@stori.com"},
+		Subject: "Statement",
+		Text:    "Hi Julieta",
+	})
+
+	require.NoError(t, err)
+	received := <-sessions
+	assert.Equal(t, "\x00stori\x00secret", received.auth)
+	assert.Equal(t, "FROM:<noreply@stori.com> BODY=8BITMIME", received.from)
+	assert.Equal(t, []string{"TO:<julieta@example.com>", "TO:<ops@stori.com>"}, received.to)
+	assert.Contains(t, received.data, "Subject: Statement\n")
+	assert.Contains(t, received.data, "Hi Julieta")
+}
+
+func TestSendEmailRequiresStartTLS(t *testing.T) {
+	host, _ := serve(t)
+	service, err := NewService(application.SmtpConfig{Host: host, TLS: TLSStartTLS}, "noreply@stori.com")
+	require.NoError(t, err)
+
+	err = service.SendEmail(context.Background(), message.Message{To: []string{"julieta@example.com"}, Text: "hi"})
+
+	assert.EqualError(t, err, "the smtp server does not offer STARTTLS")
+}
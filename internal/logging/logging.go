@@ -0,0 +1,200 @@
+// Package logging configures logrus and carries the fields that correlate the logs of a request, such as the
+// lambda request id, the s3 object and the account, in the context given to log.WithContext.
+package logging
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Formats of the logs.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config holds the logging settings, it is loaded as application.LoggingConfig.
+type Config struct {
+	// Level is debug, info (the default), warn or error.
+	Level string
+	// Format is FormatJSON (the default) or FormatText.
+	Format string
+}
+
+// Fields added by the With* functions.
+const (
+	FieldRequestID = "request_id"
+	FieldBucket    = "bucket"
+	FieldKey       = "key"
+	FieldAccountID = "account_id"
+)
+
+var hooks sync.Once
+
+// Setup configures the standard logger every package logs through.
+func Setup(config Config) error {
+	level := log.InfoLevel
+	if config.Level != "" {
+		parsed, err := log.ParseLevel(config.Level)
+		if err != nil {
+			return err
+		}
+		level = parsed
+	}
+
+	switch config.Format {
+	case "", FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	default:
+		return errors.Errorf("unknown log format %q", config.Format)
+	}
+
+	log.SetLevel(level)
+	hooks.Do(func() {
+		log.AddHook(contextHook{})
+		log.AddHook(redactHook{})
+	})
+
+	return nil
+}
+
+type fieldsKey struct{}
+
+// With returns a context whose logs carry fields besides the ones already in ctx.
+func With(ctx context.Context, fields log.Fields) context.Context {
+	merged := log.Fields{}
+	for name, value := range FieldsOf(ctx) {
+		merged[name] = value
+	}
+	for name, value := range fields {
+		merged[name] = value
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsOf returns the fields carried by ctx.
+func FieldsOf(ctx context.Context) log.Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(log.Fields)
+	return fields
+}
+
+// WithRequestID correlates the logs of a lambda invocation or an http request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return With(ctx, log.Fields{FieldRequestID: id})
+}
+
+// WithObject correlates the logs of the processing of a file.
+func WithObject(ctx context.Context, bucket, key string) context.Context {
+	return With(ctx, log.Fields{FieldBucket: bucket, FieldKey: key})
+}
+
+// WithAccount correlates the logs of an account.
+func WithAccount(ctx context.Context, accountID string) context.Context {
+	return With(ctx, log.Fields{FieldAccountID: accountID})
+}
+
+// contextHook adds the fields of the context of the entry, the fields of the call take precedence.
+type contextHook struct{}
+
+func (contextHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (contextHook) Fire(entry *log.Entry) error {
+	for name, value := range FieldsOf(entry.Context) {
+		if _, ok := entry.Data[name]; !ok {
+			entry.Data[name] = value
+		}
+	}
+	return nil
+}
+
+// Sampler keeps the first First occurrences of a repeated log and then one of every Every.
+type Sampler struct {
+	First int
+	Every int
+}
+
+// Sample reports whether the n-th occurrence, starting at 1, is logged.
+func (s Sampler) Sample(n int) bool {
+	return n <= s.First || (s.Every > 0 && n%s.Every == 0)
+}
+
+// redacted replaces the values that must not reach the logs.
+const redacted = "[redacted]"
+
+// piiFields are redacted by redactHook wherever they are logged.
+var piiFields = map[string]func(interface{}) interface{}{
+	"email":  redactAddresses,
+	"to":     redactAddresses,
+	"name":   func(interface{}) interface{} { return redacted },
+	"amount": func(interface{}) interface{} { return redacted },
+}
+
+// addressPattern matches the email addresses in free text, such as the messages of the errors of SES or SMTP.
+var addressPattern = regexp.MustCompile(`[^\s@<>()\[\],;:"']+@[^\s@<>()\[\],;:"']+\.[^\s@<>()\[\],;:"'.]+`)
+
+// redactHook redacts the piiFields of the entries and the addresses in their message.
+type redactHook struct{}
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redactHook) Fire(entry *log.Entry) error {
+	for name, redact := range piiFields {
+		if value, ok := entry.Data[name]; ok {
+			entry.Data[name] = redact(value)
+		}
+	}
+	if err, ok := entry.Data[log.ErrorKey].(error); ok {
+		entry.Data[log.ErrorKey] = RedactText(err.Error())
+	}
+	entry.Message = RedactText(entry.Message)
+	return nil
+}
+
+func redactAddresses(value interface{}) interface{} {
+	switch addresses := value.(type) {
+	case string:
+		return RedactEmail(addresses)
+	case []string:
+		return RedactEmails(addresses)
+	}
+	return redacted
+}
+
+// RedactEmail keeps the first letter of the mailbox and the domain, julieta@example.com is j***@example.com.
+func RedactEmail(address string) string {
+	mailbox, domain, ok := strings.Cut(address, "@")
+	if !ok || mailbox == "" {
+		return redacted
+	}
+	return string([]rune(mailbox)[:1]) + "***@" + domain
+}
+
+// RedactEmails redacts every address of a list of recipients.
+func RedactEmails(addresses []string) []string {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		result = append(result, RedactEmail(address))
+	}
+	return result
+}
+
+// RedactText redacts the email addresses found in text, e.g. the message of an error before it is logged or stored.
+func RedactText(text string) string {
+	return addressPattern.ReplaceAllStringFunc(text, RedactEmail)
+}
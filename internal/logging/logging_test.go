@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&log.JSONFormatter{})
+	logger.AddHook(contextHook{})
+	logger.AddHook(redactHook{})
+
+	ctx := WithRequestID(context.Background(), "c6af9ac6-7b61")
+	ctx = WithObject(ctx, "storicsv", "42/2024-08.csv")
+	ctx = WithAccount(ctx, "42")
+
+	logger.WithContext(ctx).
+		WithFields(log.Fields{
+			"event":  "send_email",
+			"key":    "override",
+			"to":     []string{"julieta@example.com", "invalid"},
+			"email":  "ñandú@example.com",
+			"amount": "-10.30",
+		}).
+		Info("sending")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "c6af9ac6-7b61", entry[FieldRequestID])
+	assert.Equal(t, "storicsv", entry[FieldBucket])
+	assert.Equal(t, "override", entry[FieldKey], "the fields of the call take precedence")
+	assert.Equal(t, "42", entry[FieldAccountID])
+	assert.Equal(t, []interface{}{"j***@example.com", "[redacted]"}, entry["to"])
+	assert.Equal(t, "ñ***@example.com", entry["email"])
+	assert.Equal(t, "[redacted]", entry["amount"])
+	assert.Nil(t, FieldsOf(context.Background()))
+}
+
+// TestRedactErrors fails when an address of an error, such as the ones SES and SMTP reply with, reaches the logs.
+func TestRedactErrors(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&log.JSONFormatter{})
+	logger.AddHook(redactHook{})
+
+	cause := errors.New("MessageRejected: Email address is not verified: julieta.p+stori@mail.example.com, <ana@example.com>")
+	logger.WithError(cause).Error(cause)
+
+	assert.NotContains(t, out.String(), "julieta.p+stori@")
+	assert.NotContains(t, out.String(), "ana@")
+	assert.Contains(t, out.String(), "j***@mail.example.com")
+	assert.Equal(t, "not verified: j***@example.com.", RedactText("not verified: julieta@example.com."))
+}
+
+func TestSetup(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	defer log.SetFormatter(log.StandardLogger().Formatter)
+
+	require.NoError(t, Setup(Config{Level: "debug"}))
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	assert.IsType(t, &log.JSONFormatter{}, log.StandardLogger().Formatter)
+
+	assert.Error(t, Setup(Config{Level: "verbose"}))
+	assert.Error(t, Setup(Config{Format: "xml"}))
+}
+
+func TestSampler(t *testing.T) {
+	sampler := Sampler{First: 2, Every: 100}
+
+	var sampled []int
+	for n := 1; n <= 300; n++ {
+		if sampler.Sample(n) {
+			sampled = append(sampled, n)
+		}
+	}
+
+	assert.Equal(t, []int{1, 2, 100, 200, 300}, sampled)
+}
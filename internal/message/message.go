@@ -0,0 +1,226 @@
+// Package message builds RFC 5322 emails with a text/plain and a text/html alternative and optional
+// attachments, the raw bytes are accepted by SES, SMTP servers and mail clients (.eml).
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"stori-challenge/internal/model"
+	"strings"
+	"time"
+)
+
+// Message is an email, at least one of Text and Html is required.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	Html    string
+	// ListUnsubscribe holds the mailto: or https: targets of the List-Unsubscribe header, an https
+	// target also enables the one-click unsubscribe of RFC 8058.
+	ListUnsubscribe []string
+	// Attachments are sent base64 encoded after the body in a multipart/mixed message.
+	Attachments []model.Attachment
+
+	// Date, MessageID and Boundary are generated when empty.
+	Date      time.Time
+	MessageID string
+	Boundary  string
+}
+
+// Bytes renders the message with CRLF line endings, the text parts are quoted-printable encoded.
+func (m Message) Bytes() ([]byte, error) {
+	if m.Text == "" && m.Html == "" {
+		return nil, errors.New("message has no body")
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid from %q", m.From)
+	}
+	to := make([]string, len(m.To))
+	for i, recipient := range m.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid recipient %d", i+1)
+		}
+		to[i] = address.String()
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID := m.MessageID
+	if messageID == "" {
+		messageID = fmt.Sprintf("<%s@%s>", randomHex(16), domain(from.Address))
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("UTF-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	if len(m.ListUnsubscribe) > 0 {
+		targets := make([]string, len(m.ListUnsubscribe))
+		oneClick := false
+		for i, target := range m.ListUnsubscribe {
+			targets[i] = "<" + target + ">"
+			oneClick = oneClick || strings.HasPrefix(target, "https:")
+		}
+		header("List-Unsubscribe", strings.Join(targets, ", "))
+		if oneClick {
+			header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+		}
+	}
+	header("MIME-Version", "1.0")
+
+	content, body, err := m.content()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Attachments) == 0 {
+		header("Content-Type", content.Get("Content-Type"))
+		if encoding := content.Get("Content-Transfer-Encoding"); encoding != "" {
+			header("Content-Transfer-Encoding", encoding)
+		}
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	// attachments wrap the content in a multipart/mixed entity, the content comes first
+	parts := multipart.NewWriter(&buf)
+	if m.Boundary != "" {
+		if err := parts.SetBoundary("mixed-" + m.Boundary); err != nil {
+			return nil, err
+		}
+	}
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": parts.Boundary()}))
+	buf.WriteString("\r\n")
+
+	writer, err := parts.CreatePart(content)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	for _, attachment := range m.Attachments {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(writer, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// content renders the text and html bodies, as a single part when only one of them is set and as a
+// multipart/alternative entity otherwise.
+func (m Message) content() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	if m.Text == "" || m.Html == "" {
+		contentType, body := "text/plain", m.Text
+		if m.Html != "" {
+			contentType, body = "text/html", m.Html
+		}
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, nil, err
+		}
+		return textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}, buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	if m.Boundary != "" {
+		if err := parts.SetBoundary(m.Boundary); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the last part is the preferred one, clients that render html pick it over the text
+	for _, part := range []struct{ contentType, body string }{{"text/plain", m.Text}, {"text/html", m.Html}} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()})},
+	}, buf.Bytes(), nil
+}
+
+// writeBase64 encodes data in lines of 76 characters.
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	writer := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(writer, body); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func domain(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
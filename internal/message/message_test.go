@@ -0,0 +1,132 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"stori-challenge/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageBytes(t *testing.T) {
+	raw, err := Message{
+		From:            "Stori <noreply@stori.com>",
+		To:              []string{"julieta@example.com"},
+		Subject:         "Resumen de cuenta – julio",
+		Text:            "Hi Julieta\nBalance: 39.74 USD",
+		Html:            "<p>Hi Julieta, your balance is 39.74 USD and this line is long enough to be wrapped by the encoder</p>",
+		ListUnsubscribe: []string{"mailto:unsubscribe@stori.com", "https://stori.com/unsubscribe"},
+		Date:            time.Date(2024, time.August, 1, 10, 0, 0, 0, time.UTC),
+		MessageID:       "<1@stori.com>",
+		Boundary:        "stori-boundary",
+	}.Bytes()
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, `"Stori" <noreply@stori.com>`, msg.Header.Get("From"))
+	assert.Equal(t, "<julieta@example.com>", msg.Header.Get("To"))
+	assert.Equal(t, "Thu, 01 Aug 2024 10:00:00 +0000", msg.Header.Get("Date"))
+	assert.Equal(t, "<mailto:unsubscribe@stori.com>, <https://stori.com/unsubscribe>", msg.Header.Get("List-Unsubscribe"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", msg.Header.Get("List-Unsubscribe-Post"))
+	assert.Equal(t, "1.0", msg.Header.Get("MIME-Version"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Resumen de cuenta – julio", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+	assert.Equal(t, "stori-boundary", params["boundary"])
+
+	// the multipart reader decodes quoted-printable parts
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", "Hi Julieta\r\nBalance: 39.74 USD"},
+		{"text/html; charset=UTF-8", "<p>Hi Julieta, your balance is 39.74 USD and this line is long enough to be wrapped by the encoder</p>"},
+	} {
+		part, err := parts.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, expected.body, string(body))
+	}
+	_, err = parts.NextPart()
+	assert.Equal(t, io.EOF, err)
+
+	for _, line := range bytes.Split(raw, []byte("\r\n")) {
+		assert.LessOrEqual(t, len(line), 998)
+		assert.NotContains(t, string(line), "\n")
+	}
+}
+
+func TestMessageBytesErrors(t *testing.T) {
+	_, err := Message{From: "noreply@stori.com", To: []string{"julieta@example.com"}}.Bytes()
+	assert.EqualError(t, err, "message has no body")
+
+	_, err = Message{From: "noreply@stori.com", To: []string{"julieta"}, Text: "hi"}.Bytes()
+	assert.EqualError(t, err, `invalid recipient 1: mail: missing '@' or angle-addr`)
+}
+
+func TestMessageBytesAttachments(t *testing.T) {
+	pdf := bytes.Repeat([]byte("%PDF-1.4\x00\xff"), 20)
+	raw, err := Message{
+		From:     "noreply@stori.com",
+		To:       []string{"julieta@example.com"},
+		Subject:  "Statement",
+		Text:     "Your statement is attached",
+		Html:     "<p>Your statement is attached</p>",
+		Boundary: "stori-boundary",
+		Attachments: []model.Attachment{
+			{Filename: "statement-42-2024-08.pdf", ContentType: "application/pdf", Data: pdf},
+			{Filename: "statement-42-2024-08.csv", ContentType: "text/csv; charset=UTF-8", Data: []byte("id,date\n1,2024-08-01\n")},
+		},
+	}.Bytes()
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := parts.NextPart()
+	require.NoError(t, err)
+	mediaType, _, err = mime.ParseMediaType(part.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	for _, expected := range []struct {
+		filename string
+		data     []byte
+	}{
+		{"statement-42-2024-08.pdf", pdf},
+		{"statement-42-2024-08.csv", []byte("id,date\n1,2024-08-01\n")},
+	} {
+		part, err := parts.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, expected.filename, part.FileName())
+		assert.Equal(t, "base64", part.Header.Get("Content-Transfer-Encoding"))
+		encoded, err := io.ReadAll(part)
+		require.NoError(t, err)
+		data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+		require.NoError(t, err)
+		assert.Equal(t, expected.data, data)
+	}
+	_, err = parts.NextPart()
+	assert.Equal(t, io.EOF, err)
+
+	for _, line := range bytes.Split(raw, []byte("\r\n")) {
+		assert.LessOrEqual(t, len(line), 998)
+	}
+}
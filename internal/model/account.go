@@ -0,0 +1,11 @@
+package model
+
+// Account holds the owner information used to address the statement of an account.
+// Currency is the ISO-4217 code the amounts of its files are expressed in.
+type Account struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Locale   string `json:"locale"`
+	Currency string `json:"currency"`
+}
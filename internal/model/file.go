@@ -0,0 +1,42 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// FileInfo describes an uploaded object without its content.
+type FileInfo struct {
+	Bucket   string
+	Key      string
+	ETag     string
+	Metadata map[string]string
+}
+
+// ProcessedFile records a file whose transactions were already ingested, Checksum is the SHA-256
+// of its content so re-uploads of the same file for the account under any key are detected.
+type ProcessedFile struct {
+	AccountID   string    `json:"account_id" pg:",pk"`
+	Checksum    string    `json:"checksum" pg:",pk"`
+	ETag        string    `json:"etag"`
+	Bucket      string    `json:"bucket"`
+	Key         string    `json:"key"`
+	Summary     *Summary  `json:"summary" pg:"type:jsonb"`
+	ProcessedAt time.Time `json:"processed_at" pg:"default:now()"`
+}
+
+// StatementID identifies the statement of a processed file, the same content uploaded for another account
+// is another statement.
+func StatementID(accountID, checksum string) string {
+	return accountID + "-" + checksum
+}
+
+// ParseStatementID splits a StatementID, the checksum has no dashes so the account is everything before
+// the last one.
+func ParseStatementID(id string) (accountID, checksum string, ok bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i <= 0 || i == len(id)-1 {
+		return "", "", false
+	}
+	return id[:i], id[i+1:], true
+}
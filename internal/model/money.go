@@ -0,0 +1,310 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when neither the account nor the value define an ISO-4217 currency.
+const DefaultCurrency = "USD"
+
+// defaultExponent is the number of minor unit digits of the currencies missing in minorUnits.
+const defaultExponent = 2
+
+// minorUnits holds the ISO-4217 exponent of the currencies whose minor unit is not the cent.
+var minorUnits = map[string]int{
+	"BHD": 3,
+	"CLP": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"PYG": 0,
+}
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// Money is an exact amount stored as minor units (e.g. cents) of an ISO-4217 currency.
+// Operations between two amounts assume both share the same currency.
+type Money struct {
+	Amount   int64
+	Currency string
+	// scale is the number of decimals of Amount when it is not the minor unit of Currency. It is only set on
+	// amounts decoded without their currency that have more decimals than DefaultCurrency, WithCurrency
+	// restores them.
+	scale int
+}
+
+// NewMoney builds an amount from its minor units.
+func NewMoney(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: NormalizeCurrency(currency)}
+}
+
+// ParseMoney parses a decimal amount such as "+60.5" or "-20.46" without going through binary floats.
+// It fails when the value has more decimals than the minor unit of the currency.
+func ParseMoney(value, currency string) (Money, error) {
+	currency = NormalizeCurrency(currency)
+	exponent := currencyExponent(currency)
+
+	minor, scale, err := parseDecimal(value, exponent)
+	if err != nil {
+		return Money{}, err
+	}
+	if scale > exponent {
+		return Money{}, errors.Wrapf(ErrInvalidMoney, "%q has more than %d decimals for %s", value, exponent, currency)
+	}
+
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// decodeMoney parses a stored decimal whose currency is not known yet, the decimals are kept exactly
+// whatever the currency turns out to be.
+func decodeMoney(value string) (Money, error) {
+	exponent := currencyExponent(DefaultCurrency)
+	minor, scale, err := parseDecimal(value, exponent)
+	if err != nil {
+		return Money{}, err
+	}
+
+	money := Money{Amount: minor, Currency: DefaultCurrency}
+	if scale != exponent {
+		money.scale = scale
+	}
+	return money, nil
+}
+
+// parseDecimal returns the value in units of 10^-scale, scale is exponent unless the value has more
+// significant decimals.
+func parseDecimal(value string, exponent int) (int64, int, error) {
+	raw := strings.TrimSpace(value)
+	negative := false
+	if raw != "" && (raw[0] == '+' || raw[0] == '-') {
+		negative = raw[0] == '-'
+		raw = raw[1:]
+	}
+
+	integer, fraction := raw, ""
+	if i := strings.IndexByte(raw, '.'); i >= 0 {
+		integer, fraction = raw[:i], raw[i+1:]
+	}
+
+	if (integer == "" && fraction == "") || !isDigits(integer) || !isDigits(fraction) {
+		return 0, 0, errors.Wrapf(ErrInvalidMoney, "%q", value)
+	}
+	if len(fraction) > exponent {
+		fraction = strings.TrimRight(fraction, "0")
+	}
+	scale := exponent
+	if len(fraction) > scale {
+		scale = len(fraction)
+	}
+
+	digits := integer + fraction + strings.Repeat("0", scale-len(fraction))
+	if digits == "" {
+		digits = "0"
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrapf(ErrInvalidMoney, "%q out of range", value)
+	}
+
+	if negative {
+		minor = -minor
+	}
+
+	return minor, scale, nil
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeCurrency upper cases the ISO-4217 code falling back to DefaultCurrency.
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+func currencyExponent(currency string) int {
+	if exponent, ok := minorUnits[currency]; ok {
+		return exponent
+	}
+	return defaultExponent
+}
+
+func (m Money) currency() string {
+	return NormalizeCurrency(m.Currency)
+}
+
+func (m Money) exponent() int {
+	if m.scale != 0 {
+		return m.scale
+	}
+	return currencyExponent(m.currency())
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currency()}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currency()}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.currency()}
+}
+
+// Div divides the amount rounding half away from zero, Div(0) returns a zero amount.
+func (m Money) Div(n int64) Money {
+	if n == 0 {
+		return Money{Currency: m.currency()}
+	}
+
+	quotient, remainder := m.Amount/n, m.Amount%n
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if 2*remainder >= abs(n) {
+		if (m.Amount < 0) != (n < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+
+	return Money{Amount: quotient, Currency: m.currency()}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// WithCurrency expresses the same decimal amount in another currency, it is used to restore amounts
+// decoded without their currency. It fails when the amount does not fit the minor unit of the currency.
+func (m Money) WithCurrency(currency string) (Money, error) {
+	if m.scale == 0 && NormalizeCurrency(currency) == m.currency() {
+		return Money{Amount: m.Amount, Currency: m.currency()}, nil
+	}
+	return ParseMoney(m.String(), currency)
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the amount.
+func (m Money) Sign() int {
+	switch {
+	case m.Amount < 0:
+		return -1
+	case m.Amount > 0:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// String formats the amount as a plain decimal, e.g. "-20.46".
+func (m Money) String() string {
+	exponent := m.exponent()
+	sign := ""
+	minor := m.Amount
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	digits := strconv.FormatInt(minor, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// Format formats the amount with its currency code, e.g. "39.74 USD".
+func (m Money) Format() string {
+	return fmt.Sprintf("%s %s", m.String(), m.currency())
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never see binary floats.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both a decimal string and a json number, the currency of the receiver is kept.
+// Without one the amount waits for WithCurrency, see decodeMoney.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return errors.Wrapf(ErrInvalidMoney, "%s", data)
+		}
+		value = number.String()
+	}
+
+	parsed, err := m.decode(value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
+
+// Value maps the amount to a postgres numeric.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a postgres numeric, the currency of the receiver is kept. Without one the amount waits for
+// WithCurrency, see decodeMoney.
+func (m *Money) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case nil:
+		*m = Money{Currency: m.currency()}
+		return nil
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case int64:
+		value = strconv.FormatInt(v, 10)
+	default:
+		return errors.Wrapf(ErrInvalidMoney, "unsupported scan type %T", src)
+	}
+
+	parsed, err := m.decode(value)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
+
+func (m Money) decode(value string) (Money, error) {
+	if m.Currency == "" {
+		return decodeMoney(value)
+	}
+	return ParseMoney(value, m.Currency)
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     Money
+		err      bool
+	}{
+		{value: "+60.5", currency: "usd", want: Money{Amount: 6050, Currency: "USD"}},
+		{value: "-20.46", want: Money{Amount: -2046, Currency: DefaultCurrency}},
+		{value: "10", currency: "MXN", want: Money{Amount: 1000, Currency: "MXN"}},
+		{value: ".5", currency: "ARS", want: Money{Amount: 50, Currency: "ARS"}},
+		{value: "1.230", currency: "USD", want: Money{Amount: 123, Currency: "USD"}},
+		{value: "1500", currency: "JPY", want: Money{Amount: 1500, Currency: "JPY"}},
+		{value: "1.005", currency: "USD", err: true},
+		{value: "1,5", err: true},
+		{value: "abc", err: true},
+		{value: "", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := ParseMoney(tc.value, tc.currency)
+			if tc.err {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMoneyBalanceDoesNotDrift(t *testing.T) {
+	balance := NewMoney(0, "USD")
+	for _, value := range []string{"60.5", "-10.3", "-20.46", "+10"} {
+		amount, err := ParseMoney(value, "USD")
+		assert.NoError(t, err)
+		balance = balance.Add(amount)
+	}
+
+	assert.Equal(t, "39.74", balance.String())
+}
+
+func TestMoneyDiv(t *testing.T) {
+	assert.Equal(t, int64(3525), NewMoney(7050, "USD").Div(2).Amount)
+	assert.Equal(t, int64(-1538), NewMoney(-3076, "USD").Div(2).Amount)
+	assert.Equal(t, int64(-3), NewMoney(-5, "USD").Div(2).Amount)
+	assert.Equal(t, int64(3), NewMoney(10, "USD").Div(3).Amount)
+	assert.True(t, NewMoney(10, "USD").Div(0).IsZero())
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "0.05", NewMoney(5, "USD").String())
+	assert.Equal(t, "-0.50", NewMoney(-50, "USD").String())
+	assert.Equal(t, "1500", NewMoney(1500, "JPY").String())
+	assert.Equal(t, "1.234", NewMoney(1234, "KWD").String())
+	assert.Equal(t, "39.74 USD", NewMoney(3974, "").Format())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	body, err := json.Marshal(Transaction{Amount: NewMoney(-2046, "USD")})
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"amount":"-20.46"`)
+
+	var transaction Transaction
+	assert.NoError(t, json.Unmarshal(body, &transaction))
+	assert.Equal(t, NewMoney(-2046, "USD"), transaction.Amount)
+
+	var number Money
+	assert.NoError(t, json.Unmarshal([]byte(`60.5`), &number))
+	assert.Equal(t, int64(6050), number.Amount)
+}
+
+func TestMoneyScan(t *testing.T) {
+	value, err := NewMoney(6050, "USD").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "60.50", value)
+
+	money := Money{Currency: "JPY"}
+	assert.NoError(t, money.Scan([]byte("1500")))
+	assert.Equal(t, NewMoney(1500, "JPY"), money)
+}
+
+func TestSummaryJSONKeepsCurrency(t *testing.T) {
+	summary := Summary{
+		Currency:       "JPY",
+		Credit:         []Transaction{{Amount: NewMoney(1500, "JPY"), Type: CREDIT}},
+		RunningBalance: NewMoney(1500, "JPY"),
+	}
+	body, err := json.Marshal(summary)
+	assert.NoError(t, err)
+
+	var decoded Summary
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, NewMoney(1500, "JPY"), decoded.RunningBalance)
+	assert.Equal(t, NewMoney(1500, "JPY"), decoded.Credit[0].Amount)
+}
+
+func TestMoneyRoundTripThreeDecimals(t *testing.T) {
+	var scanned Money
+	assert.NoError(t, scanned.Scan([]byte("-1.234")))
+	restored, err := scanned.WithCurrency("KWD")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(-1234, "KWD"), restored)
+
+	summary := Summary{
+		Currency:       "KWD",
+		Debit:          []Transaction{{Amount: NewMoney(-1234, "KWD"), Type: DEBIT}},
+		DebitCount:     1,
+		DebitTotal:     NewMoney(-1234, "KWD"),
+		RunningBalance: NewMoney(-1234, "KWD"),
+		Monthly:        []MonthSummary{{Month: "2024-08", Min: NewMoney(-1234, "KWD"), Max: NewMoney(-1234, "KWD")}},
+	}
+	body, err := json.Marshal(summary)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"balance":"-1.234"`)
+
+	var decoded Summary
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, NewMoney(-1234, "KWD"), decoded.RunningBalance)
+	assert.Equal(t, NewMoney(-1234, "KWD"), decoded.Debit[0].Amount)
+	assert.Equal(t, NewMoney(-1234, "KWD"), decoded.Monthly[0].Min)
+
+	// an amount of a 2 decimal currency still rejects the third decimal once its currency is known
+	_, err = scanned.WithCurrency("USD")
+	assert.ErrorIs(t, err, ErrInvalidMoney)
+}
@@ -1,65 +1,187 @@
 package model
 
 import (
-	"html/template"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
 	"time"
 )
 
+// TransactionType tells whether a transaction takes money out of the account (debit) or puts it in (credit).
+type TransactionType string
+
 const (
-	DEBIT  = "credit"
-	CREDIT = "debit"
+	DEBIT  TransactionType = "debit"
+	CREDIT TransactionType = "credit"
 )
 
+var ErrInvalidTransactionType = errors.New("invalid transaction type")
+
+// transactionTypes maps the names partners use for the type column.
+var transactionTypes = map[string]TransactionType{
+	"debit":  DEBIT,
+	"dr":     DEBIT,
+	"d":      DEBIT,
+	"cargo":  DEBIT,
+	"credit": CREDIT,
+	"cr":     CREDIT,
+	"c":      CREDIT,
+	"abono":  CREDIT,
+}
+
+// ParseTransactionType reads the type column of a file, names are case insensitive.
+func ParseTransactionType(value string) (TransactionType, error) {
+	if trxType, ok := transactionTypes[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return trxType, nil
+	}
+	return "", errors.Wrapf(ErrInvalidTransactionType, "%q", value)
+}
+
+// TypeOf derives the type from the sign of the amount: negative amounts are debits, the rest credits.
+func TypeOf(amount Money) TransactionType {
+	if amount.Sign() < 0 {
+		return DEBIT
+	}
+	return CREDIT
+}
+
 type Transaction struct {
-	ID     float64   `json:"id" pg:",use_zero"`
-	Amount float64   `json:"amount"`
-	Date   time.Time `json:"date"`
+	AccountID string    `json:"account_id" pg:",pk"`
+	ID        float64   `json:"id" pg:",pk,use_zero"`
+	Amount    Money     `json:"amount" pg:"type:numeric"`
+	Date      time.Time `json:"date" pg:"type:date"`
+	// Type agrees with the sign of Amount, debits are negative.
+	Type TransactionType `json:"type"`
+
+	Description string `json:"description,omitempty"`
+	Merchant    string `json:"merchant,omitempty"`
 }
 
 type Summary struct {
+	// ID identifies the statement, see StatementID.
+	ID             string        `json:"id,omitempty"`
+	AccountID      string        `json:"account_id"`
+	Currency       string        `json:"currency"`
 	Debit          []Transaction `json:"debit"`
 	Credit         []Transaction `json:"credit"`
-	RunningBalance float64       `json:"balance"`
+	RunningBalance Money         `json:"balance"`
+	UnknownColumns []string      `json:"unknown_columns,omitempty"`
+	Rejected       int           `json:"rejected"`
+	ErrorReport    string        `json:"error_report,omitempty"`
+
+	// Counts and totals cover every transaction of the file, the Debit and Credit lists only hold the
+	// transactions with the lowest ids when the file is larger than the summary limit (Truncated).
+	DebitCount  int   `json:"debit_count"`
+	CreditCount int   `json:"credit_count"`
+	DebitTotal  Money `json:"debit_total"`
+	CreditTotal Money `json:"credit_total"`
+	Truncated   bool  `json:"truncated,omitempty"`
+
+	// Monthly holds the aggregates of every calendar month of the file in chronological order.
+	Monthly []MonthSummary `json:"monthly,omitempty"`
+
+	// AlreadyProcessed is set when the file had been ingested before and this summary is the stored one.
+	AlreadyProcessed bool `json:"already_processed,omitempty"`
 }
 
-func (s *Summary) GetAverage(trx string) float64 {
-	var (
-		total        float64 = 0
-		transactions []Transaction
-		length       int
-	)
+// UnmarshalJSON restores the amounts in the currency of the summary, amounts are encoded without it.
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	type plain Summary
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
 
-	if trx == DEBIT {
-		length = len(s.Debit)
-		if length == 0 {
-			return 0
+	var err error
+	amounts := []*Money{&s.RunningBalance, &s.DebitTotal, &s.CreditTotal}
+	for i := range s.Monthly {
+		amounts = append(amounts, s.Monthly[i].amounts()...)
+	}
+	for _, amount := range amounts {
+		if *amount, err = amount.WithCurrency(s.Currency); err != nil {
+			return err
 		}
-		transactions = s.Debit
-	} else {
-		length = len(s.Credit)
-		if length == 0 {
-			return 0
+	}
+	for _, transactions := range [][]Transaction{s.Debit, s.Credit} {
+		for i := range transactions {
+			if transactions[i].Amount, err = transactions[i].Amount.WithCurrency(s.Currency); err != nil {
+				return err
+			}
 		}
-		transactions = s.Credit
 	}
 
-	for i := 0; i < length; i++ {
-		total += transactions[i].Amount
+	return nil
+}
+
+// GetAverage returns the average amount of the debit or credit transactions.
+func (s *Summary) GetAverage(trx TransactionType) Money {
+	count, total := s.CreditCount, s.CreditTotal
+	if trx == DEBIT {
+		count, total = s.DebitCount, s.DebitTotal
 	}
 
-	return total / float64(length)
+	return NewMoney(total.Amount, s.Currency).Div(int64(count))
+}
+
+// MonthSummary aggregates the transactions of a calendar month. The opening balance of the first month
+// of a statement is zero, every following month opens with the closing balance of the previous one.
+type MonthSummary struct {
+	// Month is formatted as YYYY-MM.
+	Month          string `json:"month"`
+	Count          int    `json:"count"`
+	DebitCount     int    `json:"debit_count"`
+	CreditCount    int    `json:"credit_count"`
+	DebitTotal     Money  `json:"debit_total"`
+	CreditTotal    Money  `json:"credit_total"`
+	DebitAverage   Money  `json:"debit_average"`
+	CreditAverage  Money  `json:"credit_average"`
+	OpeningBalance Money  `json:"opening_balance"`
+	ClosingBalance Money  `json:"closing_balance"`
+	// Min and Max are the lowest and the highest amount of the month.
+	Min Money `json:"min"`
+	Max Money `json:"max"`
 }
 
+// Start returns the first day of the month.
+func (m MonthSummary) Start() time.Time {
+	start, _ := time.Parse("2006-01", m.Month)
+	return start
+}
+
+func (m *MonthSummary) amounts() []*Money {
+	return []*Money{
+		&m.DebitTotal, &m.CreditTotal, &m.DebitAverage, &m.CreditAverage,
+		&m.OpeningBalance, &m.ClosingBalance, &m.Min, &m.Max,
+	}
+}
+
+// EmailParams describes an email, Template names the page of the email template set rendered with Payload
+// in the Locale of the recipient (e.g. "es-MX").
 type EmailParams struct {
 	To                []string
 	Subject, Template string
+	Locale            string
 	Payload           interface{}
+	Attachments       []Attachment
+}
+
+// Attachment is a file sent along with an email or downloaded from the API.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
 }
 
 type Data struct {
-	Name                string
-	EndingBalance       string
-	DebitAmount         string
-	CreditAmount        string
-	MonthlyTransactions template.HTML
+	Name          string
+	EndingBalance Money
+	DebitAmount   Money
+	CreditAmount  Money
+	Rejected      int
+	Months        []MonthSummary
+	// Date is when the file was processed.
+	Date time.Time
+	// StatementAttached is set when the PDF and CSV statement travel with the email, otherwise
+	// StatementURL links to the download when it is configured.
+	StatementAttached bool
+	StatementURL      string
 }
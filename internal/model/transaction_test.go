@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTransactionType(t *testing.T) {
+	assert.Equal(t, DEBIT, TypeOf(NewMoney(-1, "USD")))
+	assert.Equal(t, CREDIT, TypeOf(NewMoney(1, "USD")))
+	assert.Equal(t, CREDIT, TypeOf(NewMoney(0, "USD")))
+
+	for value, expected := range map[string]TransactionType{"Debit": DEBIT, " dr ": DEBIT, "CREDIT": CREDIT, "abono": CREDIT} {
+		trxType, err := ParseTransactionType(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, trxType, value)
+	}
+
+	_, err := ParseTransactionType("refund")
+	assert.ErrorIs(t, err, ErrInvalidTransactionType)
+}
+
+func TestGetAverage(t *testing.T) {
+	summary := Summary{
+		Currency:    "USD",
+		DebitCount:  2,
+		DebitTotal:  NewMoney(-3076, "USD"),
+		CreditCount: 2,
+		CreditTotal: NewMoney(7050, "USD"),
+	}
+
+	assert.Equal(t, NewMoney(-1538, "USD"), summary.GetAverage(DEBIT))
+	assert.Equal(t, NewMoney(3525, "USD"), summary.GetAverage(CREDIT))
+}
+
+// TestSummaryJSON decodes a stored summary as it was encoded, the amounts in its currency.
+func TestSummaryJSON(t *testing.T) {
+	stored := `{"currency": "USD", "debit": [{"id": 1, "amount": "-10.30", "type": "debit"}],
+		"credit": [{"id": 0, "amount": "60.50", "type": "credit"}],
+		"debit_count": 1, "debit_total": "-10.30", "credit_count": 1, "credit_total": "60.50", "balance": "50.20"}`
+
+	var summary Summary
+	require.NoError(t, json.Unmarshal([]byte(stored), &summary))
+
+	assert.Equal(t, []Transaction{{ID: 1, Amount: NewMoney(-1030, "USD"), Type: DEBIT}}, summary.Debit)
+	assert.Equal(t, []Transaction{{ID: 0, Amount: NewMoney(6050, "USD"), Type: CREDIT}}, summary.Credit)
+	assert.Equal(t, NewMoney(5020, "USD"), summary.RunningBalance)
+	assert.Equal(t, NewMoney(-1030, "USD"), summary.GetAverage(DEBIT))
+	assert.Equal(t, NewMoney(6050, "USD"), summary.GetAverage(CREDIT))
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import "time"
+
+// Config holds the delivery settings of the queued emails, it is loaded as application.OutboxConfig.
+type Config struct {
+	// MaxAttempts is the number of deliveries tried before an email is dead-lettered, DefaultMaxAttempts
+	// when it is not set.
+	MaxAttempts int
+	// Backoff is the wait after the first failed delivery, it doubles after every further failure up to
+	// MaxBackoff. DefaultBackoff when it is not set.
+	Backoff time.Duration
+	// BatchSize is the number of emails claimed by a dispatch, DefaultBatchSize when it is not set.
+	BatchSize int
+}
+
+const (
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = time.Minute
+	DefaultBatchSize   = 50
+	MaxBackoff         = 6 * time.Hour
+	// lease is how long a claimed email is hidden from other dispatchers, a dispatcher that dies while
+	// sending leaves it to be retried once the lease expires.
+	lease = 5 * time.Minute
+)
+
+func (c Config) maxAttempts() int {
+	if c.MaxAttempts > 0 {
+		return c.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+func (c Config) batchSize() int {
+	if c.BatchSize > 0 {
+		return c.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+// backoff returns the wait before the next delivery of an email that failed attempts times.
+func (c Config) backoff(attempts int) time.Duration {
+	wait := c.Backoff
+	if wait <= 0 {
+		wait = DefaultBackoff
+	}
+	for i := 1; i < attempts && wait < MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxBackoff {
+		wait = MaxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"stori-challenge/internal/logging"
+	"stori-challenge/internal/message"
+	"time"
+)
+
+//go:generate mockgen -source=dispatcher.go -destination=dispatcher_mock.go -package=outbox
+
+type transport interface {
+	SendEmail(context.Context, message.Message) error
+}
+
+type store interface {
+	Claim(ctx context.Context, now, until time.Time, limit int) ([]Email, error)
+	MarkSent(ctx context.Context, id int64, at time.Time) error
+	MarkFailed(ctx context.Context, id int64, cause string, next time.Time, dead bool) error
+}
+
+// Result counts the outcome of a dispatch.
+type Result struct {
+	Sent    int
+	Retried int
+	Dead    int
+}
+
+// Dispatcher delivers the queued emails through the transport.
+type Dispatcher struct {
+	store     store
+	transport transport
+	config    Config
+	now       func() time.Time
+}
+
+func NewDispatcher(store store, transport transport, config Config) *Dispatcher {
+	return &Dispatcher{store: store, transport: transport, config: config, now: time.Now}
+}
+
+// Dispatch sends the due emails until none is left, a failed delivery is retried with an exponential
+// backoff and dead-lettered after MaxAttempts. Only the errors of the store stop the dispatch.
+func (d *Dispatcher) Dispatch(ctx context.Context) (Result, error) {
+	var result Result
+	for {
+		now := d.now()
+		emails, err := d.store.Claim(ctx, now, now.Add(lease), d.config.batchSize())
+		if err != nil {
+			return result, err
+		}
+
+		for _, email := range emails {
+			if err := d.deliver(ctx, email, &result); err != nil {
+				return result, err
+			}
+		}
+
+		if len(emails) < d.config.batchSize() || ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, email Email, result *Result) error {
+	logger := log.WithContext(ctx).
+		WithFields(log.Fields{"event": "dispatch_email", "email_id": email.ID, "attempt": email.Attempts})
+
+	sendErr := d.transport.SendEmail(ctx, email.Email)
+	if sendErr == nil {
+		result.Sent++
+		return d.store.MarkSent(ctx, email.ID, d.now())
+	}
+
+	// the replies of the transports may quote the recipients
+	cause := logging.RedactText(sendErr.Error())
+	if email.Attempts >= d.config.maxAttempts() {
+		result.Dead++
+		logger.Errorf("email dead-lettered after %d attempts: %s", email.Attempts, cause)
+		return d.store.MarkFailed(ctx, email.ID, cause, d.now(), true)
+	}
+
+	next := d.now().Add(d.config.backoff(email.Attempts))
+	result.Retried++
+	logger.Warnf("email delivery failed, retrying at %s: %s", next.Format(time.RFC3339), cause)
+	return d.store.MarkFailed(ctx, email.ID, cause, next, false)
+}
+
+// Run dispatches every interval until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "dispatch_email"}).
+				Errorf("dispatch failed: %s", err.Error())
+		}
+		if result.Sent+result.Retried+result.Dead > 0 {
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "dispatch_email"}).
+				Infof("sent %d, retried %d, dead-lettered %d", result.Sent, result.Retried, result.Dead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
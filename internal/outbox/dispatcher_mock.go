@@ -0,0 +1,117 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: dispatcher.go
+
+// Package outbox is a generated GoMock package.
+package outbox
+
+import (
+	context "context"
+	reflect "reflect"
+	message "stori-challenge/internal/message"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mocktransport is a mock of transport interface.
+type Mocktransport struct {
+	ctrl     *gomock.Controller
+	recorder *MocktransportMockRecorder
+}
+
+// MocktransportMockRecorder is the mock recorder for Mocktransport.
+type MocktransportMockRecorder struct {
+	mock *Mocktransport
+}
+
+// NewMocktransport creates a new mock instance.
+func NewMocktransport(ctrl *gomock.Controller) *Mocktransport {
+	mock := &Mocktransport{ctrl: ctrl}
+	mock.recorder = &MocktransportMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mocktransport) EXPECT() *MocktransportMockRecorder {
+	return m.recorder
+}
+
+// SendEmail mocks base method.
+func (m *Mocktransport) SendEmail(arg0 context.Context, arg1 message.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendEmail", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendEmail indicates an expected call of SendEmail.
+func (mr *MocktransportMockRecorder) SendEmail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendEmail", reflect.TypeOf((*Mocktransport)(nil).SendEmail), arg0, arg1)
+}
+
+// Mockstore is a mock of store interface.
+type Mockstore struct {
+	ctrl     *gomock.Controller
+	recorder *MockstoreMockRecorder
+}
+
+// MockstoreMockRecorder is the mock recorder for Mockstore.
+type MockstoreMockRecorder struct {
+	mock *Mockstore
+}
+
+// NewMockstore creates a new mock instance.
+func NewMockstore(ctrl *gomock.Controller) *Mockstore {
+	mock := &Mockstore{ctrl: ctrl}
+	mock.recorder = &MockstoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockstore) EXPECT() *MockstoreMockRecorder {
+	return m.recorder
+}
+
+// Claim mocks base method.
+func (m *Mockstore) Claim(ctx context.Context, now, until time.Time, limit int) ([]Email, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", ctx, now, until, limit)
+	ret0, _ := ret[0].([]Email)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockstoreMockRecorder) Claim(ctx, now, until, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*Mockstore)(nil).Claim), ctx, now, until, limit)
+}
+
+// MarkFailed mocks base method.
+func (m *Mockstore) MarkFailed(ctx context.Context, id int64, cause string, next time.Time, dead bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkFailed", ctx, id, cause, next, dead)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkFailed indicates an expected call of MarkFailed.
+func (mr *MockstoreMockRecorder) MarkFailed(ctx, id, cause, next, dead interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*Mockstore)(nil).MarkFailed), ctx, id, cause, next, dead)
+}
+
+// MarkSent mocks base method.
+func (m *Mockstore) MarkSent(ctx context.Context, id int64, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSent", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkSent indicates an expected call of MarkSent.
+func (mr *MockstoreMockRecorder) MarkSent(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSent", reflect.TypeOf((*Mockstore)(nil).MarkSent), ctx, id, at)
+}
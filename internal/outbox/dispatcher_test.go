@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"stori-challenge/internal/message"
+	"testing"
+	"time"
+)
+
+func TestDispatch(t *testing.T) {
+	now := time.Date(2024, time.August, 15, 10, 0, 0, 0, time.UTC)
+	sendErr := errors.New("throttled")
+
+	tests := []struct {
+		name         string
+		attempts     int
+		sendErr      error
+		expectations func(store *Mockstore)
+		want         Result
+	}{
+		{
+			name: "sent",
+			expectations: func(store *Mockstore) {
+				store.EXPECT().MarkSent(gomock.Any(), int64(7), now).Return(nil)
+			},
+			want: Result{Sent: 1},
+		},
+		{
+			name:     "retried_with_backoff",
+			attempts: 3,
+			sendErr:  sendErr,
+			expectations: func(store *Mockstore) {
+				store.EXPECT().MarkFailed(gomock.Any(), int64(7), "throttled", now.Add(4*time.Minute), false).Return(nil)
+			},
+			want: Result{Retried: 1},
+		},
+		{
+			name:     "dead_lettered",
+			attempts: 5,
+			sendErr:  sendErr,
+			expectations: func(store *Mockstore) {
+				store.EXPECT().MarkFailed(gomock.Any(), int64(7), "throttled", now, true).Return(nil)
+			},
+			want: Result{Dead: 1},
+		},
+		{
+			name:     "recipient_redacted",
+			attempts: 1,
+			sendErr:  errors.New("MessageRejected: Email address is not verified: julieta@example.com"),
+			expectations: func(store *Mockstore) {
+				store.EXPECT().
+					MarkFailed(gomock.Any(), int64(7), "MessageRejected: Email address is not verified: j***@example.com", now.Add(time.Minute), false).
+					Return(nil)
+			},
+			want: Result{Retried: 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := NewMockstore(ctrl)
+			transport := NewMocktransport(ctrl)
+			dispatcher := NewDispatcher(store, transport, Config{MaxAttempts: 5, BatchSize: 10})
+			dispatcher.now = func() time.Time { return now }
+
+			email := message.Message{To: []string{"julieta@example.com"}, Subject: "Statement"}
+			store.EXPECT().
+				Claim(gomock.Any(), now, now.Add(lease), 10).
+				Return([]Email{{ID: 7, Email: email, Attempts: tc.attempts}}, nil)
+			transport.EXPECT().SendEmail(gomock.Any(), email).Return(tc.sendErr)
+			tc.expectations(store)
+
+			result, err := dispatcher.Dispatch(context.Background())
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, result)
+		})
+	}
+}
+
+// TestDispatchBatches claims again while the batches are full.
+func TestDispatchBatches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := NewMockstore(ctrl)
+	transport := NewMocktransport(ctrl)
+	dispatcher := NewDispatcher(store, transport, Config{BatchSize: 2})
+
+	gomock.InOrder(
+		store.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any(), 2).Return([]Email{{ID: 1}, {ID: 2}}, nil),
+		store.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any(), 2).Return([]Email{{ID: 3}}, nil),
+	)
+	transport.EXPECT().SendEmail(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	store.EXPECT().MarkSent(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+	result, err := dispatcher.Dispatch(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, Result{Sent: 3}, result)
+
+	store.EXPECT().Claim(gomock.Any(), gomock.Any(), gomock.Any(), 2).Return(nil, errors.New("connection refused"))
+	_, err = dispatcher.Dispatch(context.Background())
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestBackoff(t *testing.T) {
+	config := Config{Backoff: 30 * time.Second}
+
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		20: MaxBackoff,
+	} {
+		assert.Equal(t, want, config.backoff(attempts), attempts)
+	}
+	assert.Equal(t, DefaultBackoff, Config{}.backoff(1))
+}
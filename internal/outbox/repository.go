@@ -0,0 +1,111 @@
+// Package outbox queues the emails in postgres within the transaction of the data they report on and
+// delivers them later with retries, so a failing transport neither loses an email nor the processed file.
+package outbox
+
+import (
+	"context"
+	"github.com/go-pg/pg/v10/orm"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/message"
+	"time"
+)
+
+// Statuses of a queued email.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	// StatusDead marks the emails that failed MaxAttempts deliveries, they are kept for inspection.
+	StatusDead = "dead"
+)
+
+// Email is a row of email_outbox, Email is the rendered message.
+type Email struct {
+	tableName struct{} `pg:"email_outbox"`
+
+	ID            int64           `pg:",pk"`
+	Email         message.Message `pg:"type:jsonb"`
+	Status        string          `pg:",use_zero"`
+	Attempts      int             `pg:",use_zero"`
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time `pg:"default:now()"`
+	SentAt        time.Time
+}
+
+type Repository struct {
+	db orm.DB
+}
+
+func NewRepository(db orm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// SendEmail queues the email, it joins the transaction of the context so the email is only sent when the
+// data it reports on is committed. It makes the repository an email transport.
+func (r *Repository) SendEmail(ctx context.Context, details message.Message) error {
+	database := db.GetConnection(ctx, r.db)
+	_, err := database.ModelContext(ctx, &Email{Email: details, Status: StatusPending, NextAttemptAt: time.Now()}).Insert()
+	return err
+}
+
+// Claim leases up to limit due emails and counts the attempt, emails claimed by another dispatcher are
+// skipped. The lease expires at until.
+func (r *Repository) Claim(ctx context.Context, now, until time.Time, limit int) ([]Email, error) {
+	var emails []Email
+	database := db.GetConnection(ctx, r.db)
+	_, err := database.QueryContext(ctx, &emails, `
+		update email_outbox set attempts = attempts + 1, next_attempt_at = ?
+		where id in (
+			select id from email_outbox
+			where status = ? and next_attempt_at <= ?
+			order by next_attempt_at
+			limit ?
+			for update skip locked
+		)
+		returning *`, until, StatusPending, now, limit)
+	return emails, err
+}
+
+// MarkSent records the delivery of the email.
+func (r *Repository) MarkSent(ctx context.Context, id int64, at time.Time) error {
+	database := db.GetConnection(ctx, r.db)
+	_, err := database.ModelContext(ctx, (*Email)(nil)).
+		Set("status = ?", StatusSent).
+		Set("sent_at = ?", at).
+		Set("last_error = NULL").
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+// MarkFailed schedules the next delivery of the email at next, or dead-letters it when dead is set.
+func (r *Repository) MarkFailed(ctx context.Context, id int64, cause string, next time.Time, dead bool) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+
+	database := db.GetConnection(ctx, r.db)
+	_, err := database.ModelContext(ctx, (*Email)(nil)).
+		Set("status = ?", status).
+		Set("last_error = ?", cause).
+		Set("next_attempt_at = ?", next).
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+// Requeue gives the dead-lettered emails another MaxAttempts deliveries, it returns how many were requeued.
+func (r *Repository) Requeue(ctx context.Context, now time.Time) (int, error) {
+	database := db.GetConnection(ctx, r.db)
+	result, err := database.ModelContext(ctx, (*Email)(nil)).
+		Set("status = ?", StatusPending).
+		Set("attempts = 0").
+		Set("next_attempt_at = ?", now).
+		Where("status = ?", StatusDead).
+		Update()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,121 @@
+package statement
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+)
+
+// A4 in points.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// Fonts of the document, both are standard PDF fonts so nothing has to be embedded.
+const (
+	regular = "F1"
+	bold    = "F2"
+)
+
+// pdf writes a text-only PDF 1.4 document with the standard Helvetica fonts, content streams are
+// deflated. Text is encoded as WinAnsi, characters outside Latin-1 are replaced by '?'.
+type pdf struct {
+	pages []*bytes.Buffer
+}
+
+func (p *pdf) addPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+}
+
+// text writes s with its baseline at (x, y), the origin is the bottom left corner of the page.
+func (p *pdf) text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(p.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// line draws a thin gray line.
+func (p *pdf) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.page(), "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", x1, y1, x2, y2)
+}
+
+func (p *pdf) page() *bytes.Buffer {
+	if len(p.pages) == 0 {
+		p.addPage()
+	}
+	return p.pages[len(p.pages)-1]
+}
+
+// bytes lays out the objects: catalog, page tree, fonts and a page and a content stream per page.
+func (p *pdf) bytes() ([]byte, error) {
+	if len(p.pages) == 0 {
+		p.addPage()
+	}
+
+	var objects []string
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+
+	catalog := add("")
+	tree := add("")
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	kids := make([]string, len(p.pages))
+	for i, content := range p.pages {
+		var deflated bytes.Buffer
+		writer := zlib.NewWriter(&deflated)
+		if _, err := writer.Write(content.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		stream := add(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", deflated.Len(), deflated.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			tree, pageWidth, pageHeight, regular, bold, stream))
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", tree)
+	objects[tree-1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, xref)
+
+	return out.Bytes(), nil
+}
+
+// escape encodes s as a WinAnsi literal string.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,213 @@
+// Package statement renders the downloadable statement of a processed file as a PDF and a normalized CSV.
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/model"
+	"strconv"
+)
+
+const (
+	FormatPDF = "pdf"
+	FormatCSV = "csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown statement format")
+
+// Transactions returns the transactions listed in the summary ordered by date and id, they are every
+// transaction of the file unless the summary is truncated.
+func Transactions(summary *model.Summary) []model.Transaction {
+	transactions := make([]model.Transaction, 0, len(summary.Debit)+len(summary.Credit))
+	transactions = append(transactions, summary.Debit...)
+	transactions = append(transactions, summary.Credit...)
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if !transactions[i].Date.Equal(transactions[j].Date) {
+			return transactions[i].Date.Before(transactions[j].Date)
+		}
+		return transactions[i].ID < transactions[j].ID
+	})
+	return transactions
+}
+
+// Render builds the statement of the transactions in the given format.
+func Render(format string, owner model.Account, summary *model.Summary, transactions []model.Transaction) (*model.Attachment, error) {
+	name := filename(summary)
+	switch format {
+	case FormatPDF:
+		data, err := PDF(owner, summary, transactions)
+		if err != nil {
+			return nil, err
+		}
+		return &model.Attachment{Filename: name + ".pdf", ContentType: "application/pdf", Data: data}, nil
+	case FormatCSV:
+		data, err := CSV(summary, transactions)
+		if err != nil {
+			return nil, err
+		}
+		return &model.Attachment{Filename: name + ".csv", ContentType: "text/csv; charset=UTF-8", Data: data}, nil
+	}
+	return nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrapf(ErrUnknownFormat, "%q", format))
+}
+
+// Attachments renders the statement of the transactions listed in the summary as PDF and CSV.
+func Attachments(owner model.Account, summary *model.Summary) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	transactions := Transactions(summary)
+	for _, format := range []string{FormatPDF, FormatCSV} {
+		attachment, err := Render(format, owner, summary, transactions)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+// filename names the files after the account and the last month of the statement, e.g. statement-42-2024-08.
+func filename(summary *model.Summary) string {
+	name := "statement-" + summary.AccountID
+	if len(summary.Monthly) > 0 {
+		name += "-" + summary.Monthly[len(summary.Monthly)-1].Month
+	}
+	return name
+}
+
+// CSV writes one row per transaction with signed amounts in the currency of the account.
+func CSV(summary *model.Summary, transactions []model.Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	if err := writer.Write([]string{"id", "date", "type", "amount", "currency", "description", "merchant"}); err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactions {
+		err := writer.Write([]string{
+			strconv.FormatFloat(transaction.ID, 'f', -1, 64),
+			transaction.Date.Format("2006-01-02"),
+			string(transaction.Type),
+			transaction.Amount.String(),
+			summary.Currency,
+			transaction.Description,
+			transaction.Merchant,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+// PDF lays out the owner, the totals, the monthly aggregates and the transactions, the transactions
+// continue on as many pages as needed.
+func PDF(owner model.Account, summary *model.Summary, transactions []model.Transaction) ([]byte, error) {
+	const left, top, lineHeight = 50.0, pageHeight - 60, 14.0
+
+	doc := &pdf{}
+	doc.addPage()
+	y := top
+	newline := func(n float64) { y -= n * lineHeight }
+
+	doc.text(left, y, bold, 18, "Account statement")
+	newline(2)
+	for _, field := range [][2]string{
+		{"Name", owner.Name},
+		{"Account", summary.AccountID},
+		{"Currency", summary.Currency},
+		{"Statement", summary.ID},
+	} {
+		doc.text(left, y, bold, 10, field[0])
+		doc.text(left+90, y, regular, 10, field[1])
+		newline(1)
+	}
+
+	newline(1)
+	doc.text(left, y, bold, 12, "Summary")
+	newline(1.5)
+	for _, field := range [][2]string{
+		{"Balance", summary.RunningBalance.Format()},
+		{"Credits", fmt.Sprintf("%d transactions, %s, average %s", summary.CreditCount, summary.CreditTotal.Format(), summary.GetAverage(model.CREDIT).Format())},
+		{"Debits", fmt.Sprintf("%d transactions, %s, average %s", summary.DebitCount, summary.DebitTotal.Format(), summary.GetAverage(model.DEBIT).Format())},
+		{"Rejected rows", strconv.Itoa(summary.Rejected)},
+	} {
+		doc.text(left, y, bold, 10, field[0])
+		doc.text(left+90, y, regular, 10, field[1])
+		newline(1)
+	}
+
+	if len(summary.Monthly) > 0 {
+		newline(1)
+		doc.text(left, y, bold, 12, "Months")
+		newline(1.5)
+		columns := []float64{left, left + 70, left + 160, left + 260, left + 360}
+		row := func(font string, cells ...string) {
+			for i, cell := range cells {
+				doc.text(columns[i], y, font, 9, cell)
+			}
+			newline(1)
+		}
+		row(bold, "Month", "Transactions", "Credits", "Debits", "Closing balance")
+		for _, month := range summary.Monthly {
+			row(regular, month.Month, strconv.Itoa(month.Count), month.CreditTotal.Format(), month.DebitTotal.Format(), month.ClosingBalance.Format())
+		}
+	}
+
+	columns := []float64{left, left + 70, left + 130, left + 180, left + 410}
+	header := func() {
+		doc.text(left, y, bold, 12, "Transactions")
+		newline(1.5)
+		for i, cell := range []string{"Date", "Id", "Type", "Description", "Amount"} {
+			doc.text(columns[i], y, bold, 9, cell)
+		}
+		doc.line(left, y-4, pageWidth-left, y-4)
+		newline(1.3)
+	}
+
+	newline(1)
+	header()
+	if count := summary.DebitCount + summary.CreditCount; len(transactions) < count {
+		doc.text(left, y, regular, 9, fmt.Sprintf("Listing the first %d of %d transactions.", len(transactions), count))
+		newline(1)
+	}
+	for _, transaction := range transactions {
+		if y < 60 {
+			doc.addPage()
+			y = top
+			header()
+		}
+
+		description := transaction.Description
+		if transaction.Merchant != "" {
+			if description != "" {
+				description += " - "
+			}
+			description += transaction.Merchant
+		}
+		if runes := []rune(description); len(runes) > 45 {
+			description = string(runes[:42]) + "..."
+		}
+
+		for i, cell := range []string{
+			transaction.Date.Format("2006-01-02"),
+			strconv.FormatFloat(transaction.ID, 'f', -1, 64),
+			string(transaction.Type),
+			description,
+			transaction.Amount.Format(),
+		} {
+			doc.text(columns[i], y, regular, 9, cell)
+		}
+		newline(1)
+	}
+
+	for i, page := range doc.pages {
+		fmt.Fprintf(page, "BT /%s 8.0 Tf %.2f 30.00 Td (%s) Tj ET\n", regular, left, escape(fmt.Sprintf("Page %d of %d", i+1, len(doc.pages))))
+	}
+
+	return doc.bytes()
+}
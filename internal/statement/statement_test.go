@@ -0,0 +1,103 @@
+package statement
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"regexp"
+	"stori-challenge/internal/model"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newSummary(transactions int) *model.Summary {
+	summary := &model.Summary{ID: "abc", AccountID: "42", Currency: "USD", Monthly: []model.MonthSummary{{Month: "2024-08"}}}
+	for i := transactions - 1; i >= 0; i-- {
+		transaction := model.Transaction{
+			AccountID: "42",
+			ID:        float64(i),
+			Amount:    model.NewMoney(int64(i+1)*100, "USD"),
+			Type:      model.CREDIT,
+			Date:      time.Date(2024, time.August, 1+i%28, 0, 0, 0, 0, time.UTC),
+		}
+		if i%2 == 1 {
+			transaction.Amount, transaction.Type = transaction.Amount.Neg(), model.DEBIT
+			summary.Debit = append(summary.Debit, transaction)
+		} else {
+			summary.Credit = append(summary.Credit, transaction)
+		}
+	}
+	return summary
+}
+
+func TestCSV(t *testing.T) {
+	summary := newSummary(3)
+	summary.Credit[0].Description, summary.Credit[0].Merchant = "Coffee, large", "Café"
+
+	data, err := CSV(summary, Transactions(summary))
+
+	require.NoError(t, err)
+	assert.Equal(t, "id,date,type,amount,currency,description,merchant\n"+
+		"0,2024-08-01,credit,1.00,USD,,\n"+
+		"1,2024-08-02,debit,-2.00,USD,,\n"+
+		"2,2024-08-03,credit,3.00,USD,\"Coffee, large\",Café\n", string(data))
+}
+
+// pdfObject matches the start of an indirect object at an xref offset.
+var pdfObject = regexp.MustCompile(`^(\d+) 0 obj\n`)
+
+func TestPDF(t *testing.T) {
+	summary := newSummary(120)
+	summary.Truncated, summary.CreditCount, summary.DebitCount = true, 100, 100
+
+	data, err := PDF(model.Account{Name: "Julieta (Peña)"}, summary, Transactions(summary))
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "/Type /Pages /Kids [6 0 R 8 0 R 10 0 R] /Count 3")
+
+	// every xref entry points at its object
+	start := bytes.LastIndex(data, []byte("startxref\n"))
+	xref, err := strconv.Atoi(string(bytes.Fields(data[start+len("startxref\n"):])[0]))
+	require.NoError(t, err)
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, entries, 10)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		match := pdfObject.FindSubmatch(data[offset:])
+		require.NotNil(t, match, "object %d", i+1)
+		assert.Equal(t, strconv.Itoa(i+1), string(match[1]))
+	}
+
+	var text bytes.Buffer
+	for _, stream := range regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindAllSubmatchIndex(data, -1) {
+		length, _ := strconv.Atoi(string(data[stream[2]:stream[3]]))
+		reader, err := zlib.NewReader(bytes.NewReader(data[stream[1] : stream[1]+length]))
+		require.NoError(t, err)
+		_, err = io.Copy(&text, reader)
+		require.NoError(t, err)
+	}
+	assert.Contains(t, text.String(), "(Account statement)")
+	assert.Contains(t, text.String(), "(Julieta \\(Pe\xf1a\\))")
+	assert.Contains(t, text.String(), "(Listing the first 120 of 200 transactions.)")
+	assert.Contains(t, text.String(), "(Page 3 of 3)")
+	for _, id := range []int{0, 119} {
+		assert.Contains(t, text.String(), fmt.Sprintf("(%d)", id))
+	}
+}
+
+func TestRender(t *testing.T) {
+	attachments, err := Attachments(model.Account{}, newSummary(2))
+	require.NoError(t, err)
+	require.Len(t, attachments, 2)
+	assert.Equal(t, "statement-42-2024-08.pdf", attachments[0].Filename)
+	assert.Equal(t, "statement-42-2024-08.csv", attachments[1].Filename)
+
+	_, err = Render("xlsx", model.Account{}, newSummary(2), nil)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,185 @@
+package transaction
+
+import (
+	"container/heap"
+	"sort"
+	"stori-challenge/internal/model"
+)
+
+// aggregate folds the parsed rows of a file. It keeps sums and counts of every transaction but only
+// the lowest ids up to the summary limit, so its size does not depend on the size of the file.
+type aggregate struct {
+	transactions  *lowest[model.Transaction]
+	rejected      *lowest[*RowError]
+	rejectedCount int
+	debit         tally
+	credit        tally
+	balance       model.Money
+	months        map[string]*month
+	currency      string
+}
+
+// tally counts and sums the transactions of one side of the summary.
+type tally struct {
+	count int
+	total model.Money
+}
+
+func (t *tally) add(amount model.Money) {
+	t.count++
+	t.total = t.total.Add(amount)
+}
+
+func (t tally) average() model.Money {
+	return t.total.Div(int64(t.count))
+}
+
+// month aggregates the transactions of a calendar month.
+type month struct {
+	debit    tally
+	credit   tally
+	min, max model.Money
+}
+
+func newAggregate(currency string, summaryLimit, reportLimit int) *aggregate {
+	return &aggregate{
+		transactions: newLowest(summaryLimit, func(a, b model.Transaction) bool { return a.ID < b.ID }),
+		rejected:     newLowest(reportLimit, func(a, b *RowError) bool { return a.Line < b.Line }),
+		debit:        tally{total: model.NewMoney(0, currency)},
+		credit:       tally{total: model.NewMoney(0, currency)},
+		balance:      model.NewMoney(0, currency),
+		months:       make(map[string]*month),
+		currency:     currency,
+	}
+}
+
+func (a *aggregate) add(transaction model.Transaction) {
+	a.transactions.push(transaction)
+	key := transaction.Date.Format("2006-01")
+	m, ok := a.months[key]
+	if !ok {
+		m = &month{
+			debit:  tally{total: model.NewMoney(0, a.currency)},
+			credit: tally{total: model.NewMoney(0, a.currency)},
+			min:    transaction.Amount,
+			max:    transaction.Amount,
+		}
+		a.months[key] = m
+	}
+	if transaction.Amount.Amount < m.min.Amount {
+		m.min = transaction.Amount
+	}
+	if transaction.Amount.Amount > m.max.Amount {
+		m.max = transaction.Amount
+	}
+
+	if transaction.Type == model.DEBIT {
+		a.debit.add(transaction.Amount)
+		m.debit.add(transaction.Amount)
+	} else {
+		a.credit.add(transaction.Amount)
+		m.credit.add(transaction.Amount)
+	}
+	a.balance = a.balance.Add(transaction.Amount)
+}
+
+func (a *aggregate) reject(rowErr *RowError) {
+	a.rejected.push(rowErr)
+	a.rejectedCount++
+}
+
+// summarize splits the retained transactions into debits and credits in id order, totals and the running
+// balance cover every transaction. The running balance is a sum, so the order rows arrive in does not matter.
+func (a *aggregate) summarize(summary *model.Summary) {
+	for _, transaction := range a.transactions.sorted() {
+		if transaction.Type == model.DEBIT {
+			summary.Debit = append(summary.Debit, transaction)
+		} else {
+			summary.Credit = append(summary.Credit, transaction)
+		}
+	}
+
+	summary.DebitCount, summary.DebitTotal = a.debit.count, a.debit.total
+	summary.CreditCount, summary.CreditTotal = a.credit.count, a.credit.total
+	summary.RunningBalance = a.balance
+	summary.Truncated = a.transactions.dropped > 0
+	summary.Rejected = a.rejectedCount
+	summary.Monthly = a.monthly()
+}
+
+// monthly orders the months chronologically and carries the balance from one month to the next.
+func (a *aggregate) monthly() []model.MonthSummary {
+	keys := make([]string, 0, len(a.months))
+	for key := range a.months {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	balance := model.NewMoney(0, a.currency)
+	months := make([]model.MonthSummary, 0, len(keys))
+	for _, key := range keys {
+		m := a.months[key]
+		closing := balance.Add(m.debit.total).Add(m.credit.total)
+		months = append(months, model.MonthSummary{
+			Month:          key,
+			Count:          m.debit.count + m.credit.count,
+			DebitCount:     m.debit.count,
+			CreditCount:    m.credit.count,
+			DebitTotal:     m.debit.total,
+			CreditTotal:    m.credit.total,
+			DebitAverage:   m.debit.average(),
+			CreditAverage:  m.credit.average(),
+			OpeningBalance: balance,
+			ClosingBalance: closing,
+			Min:            m.min,
+			Max:            m.max,
+		})
+		balance = closing
+	}
+
+	return months
+}
+
+// lowest keeps the n lowest items pushed to it, a max-heap evicts the highest one when it is full.
+type lowest[T any] struct {
+	n       int
+	less    func(a, b T) bool
+	items   []T
+	dropped int
+}
+
+func newLowest[T any](n int, less func(a, b T) bool) *lowest[T] {
+	return &lowest[T]{n: n, less: less}
+}
+
+func (l *lowest[T]) push(item T) {
+	switch {
+	case len(l.items) < l.n:
+		heap.Push(l, item)
+	case l.n > 0 && l.less(item, l.items[0]):
+		l.items[0] = item
+		heap.Fix(l, 0)
+		l.dropped++
+	default:
+		l.dropped++
+	}
+}
+
+// sorted returns the items in ascending order.
+func (l *lowest[T]) sorted() []T {
+	items := make([]T, len(l.items))
+	copy(items, l.items)
+	sort.SliceStable(items, func(i, j int) bool { return l.less(items[i], items[j]) })
+	return items
+}
+
+// heap.Interface, the root is the highest item.
+func (l *lowest[T]) Len() int           { return len(l.items) }
+func (l *lowest[T]) Less(i, j int) bool { return l.less(l.items[j], l.items[i]) }
+func (l *lowest[T]) Swap(i, j int)      { l.items[i], l.items[j] = l.items[j], l.items[i] }
+func (l *lowest[T]) Push(x any)         { l.items = append(l.items, x.(T)) }
+func (l *lowest[T]) Pop() any {
+	last := l.items[len(l.items)-1]
+	l.items = l.items[:len(l.items)-1]
+	return last
+}
@@ -0,0 +1,54 @@
+package transaction
+
+import (
+	"github.com/stretchr/testify/assert"
+	"stori-challenge/internal/model"
+	"testing"
+	"time"
+)
+
+func TestAggregateMonthly(t *testing.T) {
+	result := newAggregate("USD", DefaultSummaryLimit, maxReportedRows)
+	for _, transaction := range []model.Transaction{
+		{ID: 1, Amount: model.NewMoney(-1030, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.January, 28, 0, 0, 0, 0, time.UTC)},
+		{ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: time.Date(2023, time.December, 15, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, Amount: model.NewMoney(-2046, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: 3, Amount: model.NewMoney(1000, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.January, 13, 0, 0, 0, 0, time.UTC)},
+	} {
+		result.add(transaction)
+	}
+
+	summary := &model.Summary{}
+	result.summarize(summary)
+
+	assert.Equal(t, []model.MonthSummary{
+		{
+			Month:          "2023-12",
+			Count:          1,
+			CreditCount:    1,
+			DebitTotal:     model.NewMoney(0, "USD"),
+			CreditTotal:    model.NewMoney(6050, "USD"),
+			DebitAverage:   model.NewMoney(0, "USD"),
+			CreditAverage:  model.NewMoney(6050, "USD"),
+			OpeningBalance: model.NewMoney(0, "USD"),
+			ClosingBalance: model.NewMoney(6050, "USD"),
+			Min:            model.NewMoney(6050, "USD"),
+			Max:            model.NewMoney(6050, "USD"),
+		},
+		{
+			Month:          "2024-01",
+			Count:          3,
+			DebitCount:     2,
+			CreditCount:    1,
+			DebitTotal:     model.NewMoney(-3076, "USD"),
+			CreditTotal:    model.NewMoney(1000, "USD"),
+			DebitAverage:   model.NewMoney(-1538, "USD"),
+			CreditAverage:  model.NewMoney(1000, "USD"),
+			OpeningBalance: model.NewMoney(6050, "USD"),
+			ClosingBalance: model.NewMoney(3974, "USD"),
+			Min:            model.NewMoney(-2046, "USD"),
+			Max:            model.NewMoney(1000, "USD"),
+		},
+	}, summary.Monthly)
+	assert.Equal(t, summary.RunningBalance, summary.Monthly[1].ClosingBalance)
+}
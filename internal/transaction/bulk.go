@@ -0,0 +1,170 @@
+package transaction
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/pkg/errors"
+	"stori-challenge/internal/integrations/db"
+	"stori-challenge/internal/model"
+	"strconv"
+)
+
+// InsertStrategy selects how InsertTransactions writes the history.
+type InsertStrategy string
+
+const (
+	// InsertBatch upserts the transactions with multi-row INSERT statements.
+	InsertBatch InsertStrategy = "batch"
+	// InsertCopy streams the transactions with COPY FROM STDIN into a temporary table and merges it
+	// into transactions, COPY can not resolve conflicts by itself.
+	InsertCopy InsertStrategy = "copy"
+)
+
+const DefaultInsertBatchSize = 1000
+
+// InsertConfig tunes InsertTransactions, zero values use InsertBatch and DefaultInsertBatchSize.
+type InsertConfig struct {
+	Strategy  InsertStrategy
+	BatchSize int
+}
+
+func (c InsertConfig) batchSize() int {
+	if c.BatchSize > 0 {
+		return c.BatchSize
+	}
+	return DefaultInsertBatchSize
+}
+
+// BatchError reports the batch of InsertTransactions that failed, Offset is the position of its first
+// transaction in the inserted slice. The batches before it were written.
+type BatchError struct {
+	Batch  int
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d (transactions %d to %d): %s", e.Batch, e.Offset+1, e.Offset+e.Size, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BatchError) Cause() error {
+	return e.Err
+}
+
+const (
+	createStaging = `CREATE TEMP TABLE IF NOT EXISTS transactions_staging (LIKE transactions INCLUDING DEFAULTS) ON COMMIT DROP`
+	copyStaging   = `COPY transactions_staging (account_id, id, amount, type, date, description, merchant) FROM STDIN WITH (FORMAT csv)`
+	mergeStaging  = `INSERT INTO transactions (account_id, id, amount, type, date, description, merchant)
+SELECT account_id, id, amount, type, date, description, merchant FROM transactions_staging
+ON CONFLICT (account_id, id) DO UPDATE SET
+	amount = EXCLUDED.amount, type = EXCLUDED.type, date = EXCLUDED.date,
+	description = EXCLUDED.description, merchant = EXCLUDED.merchant`
+	truncateStaging = `TRUNCATE transactions_staging`
+)
+
+// insertBatch upserts the batch with a single INSERT statement.
+func (r *Repository) insertBatch(ctx context.Context, batch []model.Transaction) error {
+	database := db.GetConnection(ctx, r.db)
+	_, err := database.ModelContext(ctx, &batch).
+		OnConflict("(account_id, id) DO UPDATE").
+		Set("amount = EXCLUDED.amount").
+		Set("type = EXCLUDED.type").
+		Set("date = EXCLUDED.date").
+		Set("description = EXCLUDED.description").
+		Set("merchant = EXCLUDED.merchant").
+		Insert()
+	return err
+}
+
+// copyBatch copies the batch into the staging table and merges it. The staging table lives as long as
+// the transaction, so the batch joins the transaction of the context or runs in its own one.
+func (r *Repository) copyBatch(ctx context.Context, batch []model.Transaction) error {
+	data, err := encodeCopy(batch)
+	if err != nil {
+		return err
+	}
+
+	return r.inTransaction(ctx, func(tx orm.DB) error {
+		if _, err := tx.ExecContext(ctx, createStaging); err != nil {
+			return errors.Wrap(err, "failed to create the staging table")
+		}
+		if _, err := tx.CopyFrom(bytes.NewReader(data), copyStaging); err != nil {
+			return errors.Wrap(err, "failed to copy transactions")
+		}
+		if _, err := tx.ExecContext(ctx, mergeStaging); err != nil {
+			return errors.Wrap(err, "failed to merge transactions")
+		}
+		_, err := tx.ExecContext(ctx, truncateStaging)
+		return err
+	})
+}
+
+func (r *Repository) inTransaction(ctx context.Context, fn func(tx orm.DB) error) error {
+	switch connection := db.GetConnection(ctx, r.db).(type) {
+	case *pg.Tx:
+		return fn(connection)
+	case *pg.DB:
+		return connection.RunInTransaction(ctx, func(tx *pg.Tx) error {
+			return fn(tx)
+		})
+	}
+	return errors.New("copy needs a postgres connection")
+}
+
+// encodeCopy renders the batch in the csv format of COPY, empty texts are written as NULL like the ORM does.
+func encodeCopy(batch []model.Transaction) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	for _, transaction := range batch {
+		err := writer.Write([]string{
+			transaction.AccountID,
+			strconv.FormatFloat(transaction.ID, 'f', -1, 64),
+			transaction.Amount.String(),
+			string(transaction.Type),
+			transaction.Date.Format("2006-01-02"),
+			transaction.Description,
+			transaction.Merchant,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
+
+// latestByKey keeps the last occurrence of every (account, id), a single statement can not upsert
+// the same key twice.
+func latestByKey(transactions []model.Transaction) []model.Transaction {
+	type key struct {
+		account string
+		id      float64
+	}
+
+	last := make(map[key]int, len(transactions))
+	for i, transaction := range transactions {
+		last[key{transaction.AccountID, transaction.ID}] = i
+	}
+	if len(last) == len(transactions) {
+		return transactions
+	}
+
+	unique := make([]model.Transaction, 0, len(last))
+	for i, transaction := range transactions {
+		if last[key{transaction.AccountID, transaction.ID}] == i {
+			unique = append(unique, transaction)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,103 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"stori-challenge/internal/model"
+	"testing"
+	"time"
+)
+
+func TestEncodeCopy(t *testing.T) {
+	date := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	data, err := encodeCopy([]model.Transaction{
+		{AccountID: "42", ID: 1, Amount: model.NewMoney(-1050, "USD"), Type: model.DEBIT, Date: date, Description: `coffee, "to go"`},
+		{AccountID: "42", ID: 2, Amount: model.NewMoney(20000, "USD"), Type: model.CREDIT, Date: date, Merchant: "acme"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "42,1,-10.50,debit,2024-01-31,\"coffee, \"\"to go\"\"\",\n42,2,200.00,credit,2024-01-31,,acme\n", string(data))
+}
+
+func TestLatestByKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []model.Transaction
+		expected     []model.Transaction
+	}{
+		{
+			name:         "unique",
+			transactions: []model.Transaction{{AccountID: "1", ID: 1}, {AccountID: "1", ID: 2}, {AccountID: "2", ID: 1}},
+			expected:     []model.Transaction{{AccountID: "1", ID: 1}, {AccountID: "1", ID: 2}, {AccountID: "2", ID: 1}},
+		},
+		{
+			name: "last_occurrence_wins",
+			transactions: []model.Transaction{
+				{AccountID: "1", ID: 1, Merchant: "first"}, {AccountID: "1", ID: 2}, {AccountID: "1", ID: 1, Merchant: "last"},
+			},
+			expected: []model.Transaction{{AccountID: "1", ID: 2}, {AccountID: "1", ID: 1, Merchant: "last"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, latestByKey(test.transactions))
+		})
+	}
+}
+
+// BenchmarkInsertTransactions compares the insert strategies against the postgres configured
+// through STORI_PG_HOST, STORI_PG_DATABASE, STORI_PG_USER and STORI_PG_PASSWORD.
+func BenchmarkInsertTransactions(b *testing.B) {
+	host := os.Getenv("STORI_PG_HOST")
+	if host == "" {
+		b.Skip("STORI_PG_HOST is not set")
+	}
+
+	database := pg.Connect(&pg.Options{
+		Addr:     host,
+		Database: os.Getenv("STORI_PG_DATABASE"),
+		User:     os.Getenv("STORI_PG_USER"),
+		Password: os.Getenv("STORI_PG_PASSWORD"),
+	})
+	defer database.Close()
+
+	ctx := context.Background()
+	const accountID = "bench"
+	_, err := database.ExecContext(ctx, `INSERT INTO accounts (id, name, email) VALUES (?, 'bench', 'bench@example.com')
+		ON CONFLICT DO NOTHING`, accountID)
+	require.NoError(b, err)
+	defer database.ExecContext(ctx, `DELETE FROM accounts WHERE id = ?`, accountID)
+
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, rows := range []int{1000, 100000} {
+		transactions := make([]model.Transaction, rows)
+		for i := range transactions {
+			transactions[i] = model.Transaction{
+				AccountID: accountID,
+				ID:        float64(i),
+				Amount:    model.NewMoney(int64(i%2000-1000), model.DefaultCurrency),
+				Type:      model.TypeOf(model.NewMoney(int64(i%2000-1000), model.DefaultCurrency)),
+				Date:      date.AddDate(0, 0, i%365),
+			}
+		}
+
+		for _, strategy := range []InsertStrategy{InsertBatch, InsertCopy} {
+			repository := NewRepository(database, InsertConfig{Strategy: strategy})
+			b.Run(fmt.Sprintf("%s/%d", strategy, rows), func(b *testing.B) {
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					require.NoError(b, repository.InsertTransactions(ctx, transactions))
+				}
+				b.ReportMetric(float64(rows*b.N)/time.Since(start).Seconds(), "rows/s")
+			})
+			_, err := database.ExecContext(ctx, `DELETE FROM transactions WHERE account_id = ?`, accountID)
+			require.NoError(b, err)
+		}
+	}
+}
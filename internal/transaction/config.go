@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"net/url"
+	"stori-challenge/internal/csvschema"
+	"stori-challenge/internal/dateparse"
+	"strings"
+)
+
+// Config holds the processing settings shared by every file, it is loaded as application.ProcessingConfig.
+type Config struct {
+	// DateFormat is the layout of the date column when the header does not hint one.
+	DateFormat dateparse.Format
+	// Schemas maps the columns of the files of every source.
+	Schemas csvschema.Registry
+	// Lenient processes the valid rows of a file and reports the rejected ones in an error report
+	// next to the input, otherwise the first invalid row aborts the whole file.
+	Lenient bool
+	// BatchSize is the number of transactions buffered before they are inserted, DefaultBatchSize when it is
+	// not set. The repository splits them into statements of InsertConfig.BatchSize.
+	// The batches only bound the memory, not the transaction: every batch of a file is inserted in the unit
+	// of work of the file and committed with its processed_files row once the whole file is read, so a
+	// rejected or duplicated file leaves no rows behind. A file of 1M rows holds that transaction, and the
+	// locks of the rows it upserted, for the whole ingest; concurrent files of the same account that upsert
+	// the same ids wait for it.
+	BatchSize int
+	// SummaryLimit is the number of transactions listed in the summary, DefaultSummaryLimit when it is not set.
+	SummaryLimit int
+	// AttachmentLimit is the largest size in bytes of the PDF and CSV statement attached to the summary
+	// email, DefaultAttachmentLimit when it is not set. Larger and truncated statements are linked instead.
+	AttachmentLimit int
+	// StatementURL is the link to the statement download sent when it is not attached, "{id}" is replaced
+	// by the statement id. Without it the email only mentions the statement is available through the API.
+	StatementURL string
+}
+
+const (
+	DefaultBatchSize    = 1000
+	DefaultSummaryLimit = 10000
+	// DefaultAttachmentLimit stays well below the 10 MB SES accepts once base64 encoded.
+	DefaultAttachmentLimit = 5 << 20
+)
+
+func (c Config) batchSize() int {
+	if c.BatchSize > 0 {
+		return c.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+func (c Config) summaryLimit() int {
+	if c.SummaryLimit > 0 {
+		return c.SummaryLimit
+	}
+	return DefaultSummaryLimit
+}
+
+func (c Config) attachmentLimit() int {
+	if c.AttachmentLimit > 0 {
+		return c.AttachmentLimit
+	}
+	return DefaultAttachmentLimit
+}
+
+func (c Config) statementURL(id string) string {
+	return strings.ReplaceAll(c.StatementURL, "{id}", url.PathEscape(id))
+}
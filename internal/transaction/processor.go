@@ -1,232 +1,463 @@
 package transaction
 
 import (
-	"bytes"
 	"context"
-	"encoding/csv"
-	"fmt"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/csvschema"
+	"stori-challenge/internal/dateparse"
 	"stori-challenge/internal/email"
+	"stori-challenge/internal/logging"
 	model "stori-challenge/internal/model"
+	"stori-challenge/internal/statement"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 )
 
 //go:generate mockgen -source=processor.go -destination=processor_mock.go -package=transaction
 
-const (
-	columnIndexId = iota
-	columnIndexDate
-	columnIndexAmount
-)
+// errAlreadyProcessed rolls back the persistence of a file another invocation recorded first.
+var errAlreadyProcessed = errors.New("file already processed")
+
+// SourceMetadataKey is the object metadata (x-amz-meta-source) naming the partner that sent the file,
+// it selects the csv schema of the file.
+const SourceMetadataKey = "source"
+
+// PeriodMetadataKey is the object metadata (x-amz-meta-statement-period) holding the end of the
+// statement period as YYYY-MM-DD or YYYY-MM, the upload date is used when it is missing.
+const PeriodMetadataKey = "statement-period"
 
 type emailService interface {
 	SendEmail(context.Context, model.EmailParams) error
 }
 
 type s3Service interface {
-	ReadFile(ctx context.Context) ([]byte, error)
+	OpenFile(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	WriteFile(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) error
+	Stat(ctx context.Context, bucket, key string) (*model.FileInfo, error)
+}
+
+type accountRepository interface {
+	GetAccount(ctx context.Context, id string) (*model.Account, error)
+}
+
+type unitOfWork interface {
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type repository interface {
 	InsertTransactions(context.Context, []model.Transaction) error
+	FindProcessedFile(ctx context.Context, accountID, etag, checksum string) (*model.ProcessedFile, error)
+	MarkProcessed(context.Context, *model.ProcessedFile) (bool, error)
+	ListTransactions(ctx context.Context, accountID string, filter TransactionFilter) ([]model.Transaction, error)
 }
 
 type Service struct {
 	repository repository
+	unitOfWork unitOfWork
+	accounts   accountRepository
 	email      emailService
 	bucket     s3Service
+	config     Config
+	now        func() time.Time
 }
 
-func NewService(emailService emailService, s3Service s3Service, repo repository) *Service {
+func NewService(
+	emailService emailService,
+	s3Service s3Service,
+	repo repository,
+	unitOfWork unitOfWork,
+	accounts accountRepository,
+	config Config,
+) *Service {
 	return &Service{
 		email:      emailService,
 		bucket:     s3Service,
 		repository: repo,
+		unitOfWork: unitOfWork,
+		accounts:   accounts,
+		config:     config,
+		now:        time.Now,
 	}
 }
 
-func (s *Service) getTransactions(ctx context.Context) ([]byte, error) {
-	return s.bucket.ReadFile(ctx)
+// getAccount resolves the owner of the file from the object metadata or its key.
+func (s *Service) getAccount(ctx context.Context, key string, metadata map[string]string) (*model.Account, error) {
+	id, err := account.IDFromObject(key, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.accounts.GetAccount(ctx, id)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+	return owner, nil
 }
 
-func (s *Service) getRecordsFromFile(ctx context.Context) ([][]string, error) {
-	file, err := s.getTransactions(ctx)
+// getDateParser picks the date format hinted in the header of the date column or the configured one,
+// years missing in the file are inferred from the statement period or now.
+func (s *Service) getDateParser(metadata map[string]string, mapping *csvschema.Mapping, now time.Time) (*dateparse.Parser, error) {
+	format := s.config.DateFormat
+	if hint, ok := dateparse.FromHeader(mapping.HeaderOf(csvschema.ColumnDate)); ok {
+		format = hint
+	}
+
+	periodEnd := now.UTC()
+	if value, ok := metadata[PeriodMetadataKey]; ok {
+		end, err := dateparse.PeriodEnd(value)
+		if err != nil {
+			return nil, err
+		}
+		periodEnd = end
+	}
+
+	return dateparse.NewParser(format, periodEnd), nil
+}
+
+// getMapping locates the columns of the file with the schema of its source, unknown columns are reported
+// but do not stop the processing.
+func (s *Service) getMapping(ctx context.Context, metadata map[string]string, firstRow []string) (*csvschema.Mapping, error) {
+	mapping, err := s.config.Schemas.For(metadata[SourceMetadataKey]).Map(firstRow)
 	if err != nil {
 		log.WithContext(ctx).
-			WithFields(log.Fields{"event": "records_from_file"}).
-			Errorf("failed to get transactions %s", err.Error())
+			WithFields(log.Fields{"event": "csv_schema"}).
+			Errorf("invalid csv header %s", err.Error())
 		return nil, err
 	}
-	reader := bytes.NewReader(file)
-	csvReader := csv.NewReader(reader)
-	csvReader.Comma = ','
 
-	return csvReader.ReadAll()
+	if len(mapping.Unknown) > 0 {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "csv_schema"}).
+			Warnf("unknown columns: %v", mapping.Unknown)
+	}
+
+	return mapping, nil
+}
+
+// writeErrorReport uploads the rejected rows under reportKey, see ErrorReportKey.
+func (s *Service) writeErrorReport(ctx context.Context, bucket, reportKey string, result *aggregate) error {
+	report, err := buildErrorReport(result.rejected.sorted(), result.rejected.dropped)
+	if err == nil {
+		err = s.bucket.WriteFile(ctx, bucket, reportKey, report, nil)
+	}
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "error_report"}).
+			Errorf("failed to write error report %s", err.Error())
+	}
+	return err
+}
+
+// findProcessed looks for a previous ingestion of the file for the account, the stored summary is returned
+// flagged as already processed or nil when the file is new.
+func (s *Service) findProcessed(ctx context.Context, accountID, etag, checksum string) (*model.Summary, error) {
+	processed, err := s.repository.FindProcessedFile(ctx, accountID, etag, checksum)
+	if err != nil || processed == nil {
+		return nil, err
+	}
+
+	log.WithContext(ctx).
+		WithFields(log.Fields{"event": "process_csv"}).
+		Infof("file already processed as %s/%s", processed.Bucket, processed.Key)
+
+	summary := processed.Summary
+	if summary == nil {
+		summary = &model.Summary{AccountID: processed.AccountID}
+	}
+	summary.ID = model.StatementID(processed.AccountID, processed.Checksum)
+	summary.AlreadyProcessed = true
+
+	return summary, nil
+}
+
+// summaryEmail addresses the summary to the owner, the statement is attached as PDF and CSV unless it is
+// truncated or larger than the attachment limit, the email links to the download then.
+func (s *Service) summaryEmail(ctx context.Context, owner *model.Account, summary *model.Summary) model.EmailParams {
+	data := model.Data{
+		Name:          owner.Name,
+		EndingBalance: summary.RunningBalance,
+		DebitAmount:   summary.GetAverage(model.DEBIT),
+		CreditAmount:  summary.GetAverage(model.CREDIT),
+		Rejected:      summary.Rejected,
+		Months:        summary.Monthly,
+		Date:          s.now(),
+	}
+	params := model.EmailParams{
+		To:       []string{owner.Email},
+		Template: email.TemplateSummary,
+		Locale:   owner.Locale,
+	}
+
+	attachments, err := s.attachments(*owner, summary)
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "statement_attachment"}).
+			Warnf("statement not attached: %s", err.Error())
+		data.StatementURL = s.config.statementURL(summary.ID)
+	} else {
+		params.Attachments = attachments
+		data.StatementAttached = true
+	}
+
+	params.Payload = data
+	return params
+}
+
+// attachments renders the statement when it can be attached to the summary email.
+func (s *Service) attachments(owner model.Account, summary *model.Summary) ([]model.Attachment, error) {
+	if summary.Truncated {
+		return nil, errors.Errorf("the summary lists %d of %d transactions", len(summary.Debit)+len(summary.Credit), summary.DebitCount+summary.CreditCount)
+	}
+
+	attachments, err := statement.Attachments(owner, summary)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 0
+	for _, attachment := range attachments {
+		size += len(attachment.Data)
+	}
+	if limit := s.config.attachmentLimit(); size > limit {
+		return nil, errors.Errorf("%d bytes exceed the limit of %d", size, limit)
+	}
+
+	return attachments, nil
 }
 
-// ProcessCsv it is in charge of obtaining the csv from the bucket, processing the transactions and
+// ProcessCsv it is in charge of obtaining the csv stored under key in the bucket, processing the transactions and
 // sending the corresponding email with the results.
-// the processed transactions are stored in the database as a history.
-func (s *Service) ProcessCsv(ctx context.Context) (
+// the processed transactions are stored in the database as a history. Files are identified by their etag and
+// checksum, a file that was already processed returns its previous summary without storing or emailing again.
+// The file is streamed, so the memory used does not depend on its size. The email is handed to the email
+// service within the unit of work of the file, the services that persist the file queue it in the same
+// transaction through outbox.Repository so it is only sent once the file is committed.
+func (s *Service) ProcessCsv(ctx context.Context, bucket, key string) (
 	summary *model.Summary,
 	err error,
 ) {
-	const numWorkers = 5
 	var (
-		monthlyGrouping = make(map[string]int)
-		emails          = []string{"benitezjulietasol@gmail.com"}
-		wg              sync.WaitGroup
-		recordChan      = make(chan []string)
-		errChan         = make(chan error, 1)
-		runningBalance  float64
+		result *aggregate
+		owner  *model.Account
+		stored *model.Summary
 	)
 
 	summary = &model.Summary{}
+	ctx = logging.WithObject(ctx, bucket, key)
 
-	defer func(emails []string) {
-		if err == nil {
-			err = s.email.SendEmail(ctx, model.EmailParams{
-				To:       emails,
-				Subject:  email.SubjectEmail,
-				Template: email.TemplateSummary,
-				Payload: model.Data{
-					Name:                fmt.Sprintf("%s", emails),
-					EndingBalance:       fmt.Sprintf("%.2f", runningBalance),
-					DebitAmount:         fmt.Sprintf("%.2f", summary.GetAverage(model.DEBIT)),
-					CreditAmount:        fmt.Sprintf("%.2f", summary.GetAverage(model.CREDIT)),
-					MonthlyTransactions: email.GenerateMonthlySummary(monthlyGrouping),
-				},
-			})
-			if err != nil {
-				log.WithContext(ctx).
-					WithFields(log.Fields{"event": "process_csv"}).
-					Fatalf("fail sending email %s", err.Error())
-				return
-			}
-		}
-	}(emails)
+	info, err := s.bucket.Stat(ctx, bucket, key)
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "process_csv"}).
+			Errorf("failed to get metadata %s", err.Error())
+		return nil, apperr.Wrap(apperr.ErrSourceUnavailable, err)
+	}
 
-	records, err := s.getRecordsFromFile(ctx)
+	owner, err = s.getAccount(ctx, key, info.Metadata)
 	if err != nil {
 		return
 	}
+	ctx = logging.WithAccount(ctx, owner.ID)
+	summary.AccountID = owner.ID
+	summary.Currency = model.NormalizeCurrency(owner.Currency)
+
+	// the etag avoids downloading a file the account already processed
+	if processed, err := s.findProcessed(ctx, owner.ID, info.ETag, ""); err != nil || processed != nil {
+		return processed, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+
+	// the batches are inserted while the file is streamed, they are committed together with the bookkeeping
+	// once the whole file is read and rolled back when it turns out to be a duplicate
+	err = s.unitOfWork.Run(ctx, func(ctx context.Context) error {
+		var (
+			checksum string
+			err      error
+		)
+		result, checksum, err = s.ingest(ctx, bucket, key, info.Metadata, summary)
+		if err != nil {
+			return err
+		}
+		summary.ID = model.StatementID(owner.ID, checksum)
 
-	wg.Add(numWorkers)
-	for i := 0; i < numWorkers; i++ {
-		go func() {
-			defer wg.Done()
-			for record := range recordChan {
-				err := processRecord(
-					ctx,
-					record,
-					summary,
-					monthlyGrouping,
-					&runningBalance,
-				)
-				if err != nil {
-					errChan <- err
-					return
-				}
-			}
-		}()
-	}
-
-	// sync records to workers
-	headerIndex := 0
-	for i, record := range records {
-		if i <= headerIndex {
-			continue
+		// the same content may be uploaded again under another key
+		stored, err = s.findProcessed(ctx, owner.ID, "", checksum)
+		if err != nil {
+			return apperr.Wrap(apperr.ErrPersistence, err)
 		}
-		select {
-		case recordChan <- record:
-		case err := <-errChan:
-			close(recordChan)
-			return nil, err
+		if stored != nil {
+			return errAlreadyProcessed
 		}
-	}
-	close(recordChan)
-	wg.Wait()
 
-	close(errChan)
-	for e := range errChan {
-		if e != nil {
-			return nil, e
+		result.summarize(summary)
+		if summary.Rejected > 0 {
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "process_csv"}).
+				Warnf("%d rows rejected", summary.Rejected)
+			// the report is uploaded once the file is committed, a rolled back file leaves none behind
+			summary.ErrorReport = ErrorReportKey(key)
 		}
-	}
 
-	go func() {
-		transactions := summary.Debit
-		transactions = append(transactions, summary.Credit...)
-		err := s.repository.InsertTransactions(ctx, transactions)
+		marked, err := s.repository.MarkProcessed(ctx, &model.ProcessedFile{
+			Checksum:  checksum,
+			ETag:      info.ETag,
+			Bucket:    bucket,
+			Key:       key,
+			AccountID: owner.ID,
+			Summary:   summary,
+		})
 		if err != nil {
+			return apperr.Wrap(apperr.ErrPersistence, errors.Wrap(err, "fail to mark the file as processed"))
+		}
+		if !marked {
+			return errAlreadyProcessed
+		}
+
+		// the email is queued in this transaction, a failure rolls the file back so both are retried together
+		if err := s.email.SendEmail(ctx, s.summaryEmail(ctx, owner, summary)); err != nil {
 			log.WithContext(ctx).
 				WithFields(log.Fields{"event": "process_csv"}).
-				Fatalf("fail to insert transactions: %s", err.Error())
+				Errorf("fail sending email %s", err.Error())
+			return apperr.Wrap(apperr.ErrNotification, errors.Wrap(err, "fail to send the summary email"))
 		}
-	}()
 
-	summary.RunningBalance = runningBalance
+		return nil
+	})
+	if errors.Is(err, errAlreadyProcessed) {
+		if stored != nil {
+			return stored, nil
+		}
+		// another invocation committed the same file meanwhile, its transactions are the same ones
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "process_csv"}).
+			Info("file processed concurrently by another invocation")
+		summary.AlreadyProcessed = true
+		return summary, nil
+	}
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "process_csv"}).
+			Errorf("fail to persist transactions: %s", err.Error())
+		return nil, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+
+	// the transactions are committed, a missing report is not worth processing the file again
+	if summary.ErrorReport != "" && s.writeErrorReport(ctx, bucket, summary.ErrorReport, result) != nil {
+		summary.ErrorReport = ""
+	}
+
 	return summary, nil
 }
 
-func processRecord(ctx context.Context, record []string, summary *model.Summary, monthlyGrouping map[string]int, runningBalance *float64) error {
+// rowParser turns the rows of a single file into transactions, it is shared read-only by the workers.
+type rowParser struct {
+	accountID string
+	currency  string
+	dates     *dateparse.Parser
+	mapping   *csvschema.Mapping
+}
+
+// rowSampler keeps the debug logs of the rows from flooding the logs of large files.
+var rowSampler = logging.Sampler{First: 10, Every: 1000}
+
+// logRecord logs a sample of the parsed rows at debug level, the amounts are redacted and the values of
+// rejected rows are left out.
+func logRecord(ctx context.Context, line int, transaction *model.Transaction, err *error) {
+	if !log.IsLevelEnabled(log.DebugLevel) || !rowSampler.Sample(line) {
+		return
+	}
+
+	logger := log.WithContext(ctx).WithFields(log.Fields{"event": "process_record", "line": line})
+	var rowErr *RowError
+	if errors.As(*err, &rowErr) {
+		logger.WithFields(log.Fields{"column": rowErr.Column}).Debug("row rejected")
+		return
+	}
+	logger.WithFields(log.Fields{"id": transaction.ID, "type": transaction.Type, "amount": transaction.Amount}).
+		Debug("row parsed")
+}
+
+// processRecord parses a single csv row into a transaction, every failure is reported as a *RowError.
+func (p *rowParser) processRecord(ctx context.Context, record row) (transaction model.Transaction, err error) {
 	const bitSize = 64
 
-	log.WithContext(ctx).
-		WithFields(log.Fields{"event": "process_record"}).
-		Infof("parsing row: %v", record)
+	defer logRecord(ctx, record.line, &transaction, &err)
 
-	id, err := strconv.ParseFloat(record[columnIndexId], bitSize)
+	if record.err != nil {
+		return model.Transaction{}, newRowError(record.line, "", strings.Join(record.fields, ","), record.err)
+	}
+
+	rawId, err := p.mapping.Required(record.fields, csvschema.ColumnID)
 	if err != nil {
-		log.WithContext(ctx).
-			WithFields(log.Fields{"event": "process_record"}).
-			Errorf("failed to parse date %s:", record[columnIndexDate])
-		return err
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnID, rawId, err)
+	}
+	id, err := strconv.ParseFloat(rawId, bitSize)
+	if err != nil {
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnID, rawId, err)
+	}
+	// The id column is an integer, postgres would round fractional ids into another transaction's id
+	if id != math.Trunc(id) || id < math.MinInt32 || id > math.MaxInt32 {
+		err := errors.Errorf("must be an integer between %d and %d", math.MinInt32, math.MaxInt32)
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnID, rawId, err)
 	}
 
 	// Parse date
-	date, err := time.Parse("1/2", record[columnIndexDate])
+	rawDate, err := p.mapping.Required(record.fields, csvschema.ColumnDate)
 	if err != nil {
-		log.WithContext(ctx).
-			WithFields(log.Fields{"event": "process_record"}).
-			Errorf("failed to parse date %s:", record[columnIndexDate])
-		return err
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnDate, rawDate, err)
+	}
+	date, err := p.dates.Parse(rawDate)
+	if err != nil {
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnDate, rawDate, err)
 	}
 
-	log.WithContext(ctx).
-		WithFields(log.Fields{"event": "process_record"}).
-		Infof("Parsed date: %v", date)
+	// Rows can only carry the currency of the account, the balance would not add up otherwise
+	if currency, ok := p.mapping.Get(record.fields, csvschema.ColumnCurrency); ok && currency != "" &&
+		model.NormalizeCurrency(currency) != p.currency {
+		err := errors.Errorf("does not match the account currency %s", p.currency)
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnCurrency, currency, err)
+	}
 
 	// Parse amount. debit is negative and credit is positive
-	amount, err := strconv.ParseFloat(record[columnIndexAmount], bitSize)
+	rawAmount, err := p.mapping.Required(record.fields, csvschema.ColumnAmount)
 	if err != nil {
-		log.WithContext(ctx).
-			WithFields(log.Fields{"event": "process_record"}).
-			Errorf("failed to parse amount %s", record[columnIndexAmount])
-		return err
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnAmount, rawAmount, err)
 	}
-
-	log.WithContext(ctx).
-		WithFields(log.Fields{"event": "process_record"}).
-		Infof("Parsed amount: %v", amount)
-
-	monthlyGrouping[date.Month().String()] = monthlyGrouping[date.Month().String()] + 1
-
-	transaction := model.Transaction{
-		ID:     id,
-		Amount: amount,
-		Date:   date,
+	amount, err := model.ParseMoney(rawAmount, p.currency)
+	if err != nil {
+		return model.Transaction{}, newRowError(record.line, csvschema.ColumnAmount, rawAmount, err)
 	}
 
-	if amount > 0 {
-		summary.Debit = append(summary.Debit, transaction)
-	} else {
-		summary.Credit = append(summary.Credit, transaction)
+	// An explicit type signs the amount, files that carry it usually write debits without the minus sign
+	trxType := model.TypeOf(amount)
+	if rawType, ok := p.mapping.Get(record.fields, csvschema.ColumnType); ok && rawType != "" {
+		if trxType, err = model.ParseTransactionType(rawType); err != nil {
+			return model.Transaction{}, newRowError(record.line, csvschema.ColumnType, rawType, err)
+		}
+		if trxType == model.CREDIT && amount.Sign() < 0 {
+			err := errors.Errorf("credit with the negative amount %s", rawAmount)
+			return model.Transaction{}, newRowError(record.line, csvschema.ColumnType, rawType, err)
+		}
+		if trxType == model.DEBIT && amount.Sign() > 0 {
+			amount = amount.Neg()
+		}
 	}
 
-	*runningBalance += amount
-
-	return nil
+	description, _ := p.mapping.Get(record.fields, csvschema.ColumnDescription)
+	merchant, _ := p.mapping.Get(record.fields, csvschema.ColumnMerchant)
+
+	return model.Transaction{
+		AccountID:   p.accountID,
+		ID:          id,
+		Amount:      amount,
+		Date:        date,
+		Type:        trxType,
+		Description: description,
+		Merchant:    merchant,
+	}, nil
 }
@@ -6,6 +6,7 @@ package transaction
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	model "stori-challenge/internal/model"
 
@@ -72,19 +73,123 @@ func (m *Mocks3Service) EXPECT() *Mocks3ServiceMockRecorder {
 	return m.recorder
 }
 
-// ReadFile mocks base method.
-func (m *Mocks3Service) ReadFile(ctx context.Context) ([]byte, error) {
+// OpenFile mocks base method.
+func (m *Mocks3Service) OpenFile(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadFile", ctx)
-	ret0, _ := ret[0].([]byte)
+	ret := m.ctrl.Call(m, "OpenFile", ctx, bucket, key)
+	ret0, _ := ret[0].(io.ReadCloser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ReadFile indicates an expected call of ReadFile.
-func (mr *Mocks3ServiceMockRecorder) ReadFile(ctx interface{}) *gomock.Call {
+// OpenFile indicates an expected call of OpenFile.
+func (mr *Mocks3ServiceMockRecorder) OpenFile(ctx, bucket, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadFile", reflect.TypeOf((*Mocks3Service)(nil).ReadFile), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenFile", reflect.TypeOf((*Mocks3Service)(nil).OpenFile), ctx, bucket, key)
+}
+
+// Stat mocks base method.
+func (m *Mocks3Service) Stat(ctx context.Context, bucket, key string) (*model.FileInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stat", ctx, bucket, key)
+	ret0, _ := ret[0].(*model.FileInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stat indicates an expected call of Stat.
+func (mr *Mocks3ServiceMockRecorder) Stat(ctx, bucket, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stat", reflect.TypeOf((*Mocks3Service)(nil).Stat), ctx, bucket, key)
+}
+
+// WriteFile mocks base method.
+func (m *Mocks3Service) WriteFile(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteFile", ctx, bucket, key, body, metadata)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteFile indicates an expected call of WriteFile.
+func (mr *Mocks3ServiceMockRecorder) WriteFile(ctx, bucket, key, body, metadata interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteFile", reflect.TypeOf((*Mocks3Service)(nil).WriteFile), ctx, bucket, key, body, metadata)
+}
+
+// MockaccountRepository is a mock of accountRepository interface.
+type MockaccountRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockaccountRepositoryMockRecorder
+}
+
+// MockaccountRepositoryMockRecorder is the mock recorder for MockaccountRepository.
+type MockaccountRepositoryMockRecorder struct {
+	mock *MockaccountRepository
+}
+
+// NewMockaccountRepository creates a new mock instance.
+func NewMockaccountRepository(ctrl *gomock.Controller) *MockaccountRepository {
+	mock := &MockaccountRepository{ctrl: ctrl}
+	mock.recorder = &MockaccountRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockaccountRepository) EXPECT() *MockaccountRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetAccount mocks base method.
+func (m *MockaccountRepository) GetAccount(ctx context.Context, id string) (*model.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
+	ret0, _ := ret[0].(*model.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockaccountRepositoryMockRecorder) GetAccount(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockaccountRepository)(nil).GetAccount), ctx, id)
+}
+
+// MockunitOfWork is a mock of unitOfWork interface.
+type MockunitOfWork struct {
+	ctrl     *gomock.Controller
+	recorder *MockunitOfWorkMockRecorder
+}
+
+// MockunitOfWorkMockRecorder is the mock recorder for MockunitOfWork.
+type MockunitOfWorkMockRecorder struct {
+	mock *MockunitOfWork
+}
+
+// NewMockunitOfWork creates a new mock instance.
+func NewMockunitOfWork(ctrl *gomock.Controller) *MockunitOfWork {
+	mock := &MockunitOfWork{ctrl: ctrl}
+	mock.recorder = &MockunitOfWorkMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockunitOfWork) EXPECT() *MockunitOfWorkMockRecorder {
+	return m.recorder
+}
+
+// Run mocks base method.
+func (m *MockunitOfWork) Run(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Run", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockunitOfWorkMockRecorder) Run(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockunitOfWork)(nil).Run), ctx, fn)
 }
 
 // Mockrepository is a mock of repository interface.
@@ -110,6 +215,21 @@ func (m *Mockrepository) EXPECT() *MockrepositoryMockRecorder {
 	return m.recorder
 }
 
+// FindProcessedFile mocks base method.
+func (m *Mockrepository) FindProcessedFile(ctx context.Context, accountID, etag, checksum string) (*model.ProcessedFile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindProcessedFile", ctx, accountID, etag, checksum)
+	ret0, _ := ret[0].(*model.ProcessedFile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindProcessedFile indicates an expected call of FindProcessedFile.
+func (mr *MockrepositoryMockRecorder) FindProcessedFile(ctx, accountID, etag, checksum interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindProcessedFile", reflect.TypeOf((*Mockrepository)(nil).FindProcessedFile), ctx, accountID, etag, checksum)
+}
+
 // InsertTransactions mocks base method.
 func (m *Mockrepository) InsertTransactions(arg0 context.Context, arg1 []model.Transaction) error {
 	m.ctrl.T.Helper()
@@ -123,3 +243,33 @@ func (mr *MockrepositoryMockRecorder) InsertTransactions(arg0, arg1 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertTransactions", reflect.TypeOf((*Mockrepository)(nil).InsertTransactions), arg0, arg1)
 }
+
+// ListTransactions mocks base method.
+func (m *Mockrepository) ListTransactions(ctx context.Context, accountID string, filter TransactionFilter) ([]model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", ctx, accountID, filter)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockrepositoryMockRecorder) ListTransactions(ctx, accountID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*Mockrepository)(nil).ListTransactions), ctx, accountID, filter)
+}
+
+// MarkProcessed mocks base method.
+func (m *Mockrepository) MarkProcessed(arg0 context.Context, arg1 *model.ProcessedFile) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkProcessed", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkProcessed indicates an expected call of MarkProcessed.
+func (mr *MockrepositoryMockRecorder) MarkProcessed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkProcessed", reflect.TypeOf((*Mockrepository)(nil).MarkProcessed), arg0, arg1)
+}
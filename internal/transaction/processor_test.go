@@ -1,18 +1,39 @@
 package transaction
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"stori-challenge/internal/account"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/csvschema"
+	"stori-challenge/internal/dateparse"
 	"stori-challenge/internal/model"
 	"testing"
 	"time"
 )
 
+// runInline executes the unit of work without a database transaction.
+func runInline(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
+
+// openFile returns a new reader over content on every call, like a fresh GetObject body.
+func openFile(content []byte) func(context.Context, string, string) (io.ReadCloser, error) {
+	return func(context.Context, string, string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+}
+
 func GetBytesFile(filePath string) ([]byte, error) {
 	path, err := filepath.Abs(filePath)
 	if err != nil {
@@ -21,15 +42,6 @@ func GetBytesFile(filePath string) ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
-func toDate(record string) time.Time {
-	date, err := time.Parse("1/2", record)
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
-	return date
-}
-
 func TestProcessCsv(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -37,12 +49,39 @@ func TestProcessCsv(t *testing.T) {
 	txsRepository := NewMockrepository(ctrl)
 	sesService := NewMockemailService(ctrl)
 	bucketService := NewMocks3Service(ctrl)
+	unitOfWork := NewMockunitOfWork(ctrl)
+	accountRepository := NewMockaccountRepository(ctrl)
 	procService := NewService(
 		sesService,
 		bucketService,
 		txsRepository,
+		unitOfWork,
+		accountRepository,
+		Config{},
 	)
 
+	owner := &model.Account{ID: "42", Name: "Julieta", Email: "julieta@example.com", Locale: "en"}
+	expectAccount := func() {
+		procService.bucket.(*Mocks3Service).
+			EXPECT().
+			Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+			Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{PeriodMetadataKey: "2024-08"}}, nil)
+		procService.repository.(*Mockrepository).
+			EXPECT().
+			FindProcessedFile(gomock.Any(), "42", "etag", "").
+			Return(nil, nil)
+		procService.accounts.(*MockaccountRepository).
+			EXPECT().
+			GetAccount(gomock.Any(), "42").
+			Return(owner, nil)
+	}
+	expectNewChecksum := func() {
+		procService.repository.(*Mockrepository).
+			EXPECT().
+			FindProcessedFile(gomock.Any(), "42", "", gomock.Len(64)).
+			Return(nil, nil)
+	}
+
 	requestRaw, err := GetBytesFile("input/transactions.csv")
 	if err != nil {
 		fmt.Println(err.Error())
@@ -51,6 +90,8 @@ func TestProcessCsv(t *testing.T) {
 	type want struct {
 		summary *model.Summary
 		err     error
+		// kind is the apperr kind the error is classified as
+		kind error
 	}
 
 	tests := []struct {
@@ -58,59 +99,800 @@ func TestProcessCsv(t *testing.T) {
 		expectations func()
 		want         want
 	}{
+		{
+			name: "error_account",
+			expectations: func() {
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+					Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{}}, nil)
+				procService.accounts.(*MockaccountRepository).
+					EXPECT().
+					GetAccount(gomock.Any(), "42").
+					Return(nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrap(account.ErrNotFound, "account 42")))
+			},
+			want: want{
+				summary: &model.Summary{},
+				err:     errors.New("account 42: account not found"),
+				kind:    apperr.ErrNotFound,
+			},
+		},
+		{
+			name: "error_account_lookup",
+			expectations: func() {
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+					Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{}}, nil)
+				procService.accounts.(*MockaccountRepository).
+					EXPECT().
+					GetAccount(gomock.Any(), "42").
+					Return(nil, errors.New("connection refused"))
+			},
+			want: want{
+				summary: &model.Summary{},
+				err:     errors.New("connection refused"),
+				kind:    apperr.ErrPersistence,
+			},
+		},
+		{
+			name: "already_processed_etag",
+			expectations: func() {
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+					Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{}}, nil)
+				procService.accounts.(*MockaccountRepository).
+					EXPECT().
+					GetAccount(gomock.Any(), "42").
+					Return(owner, nil)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					FindProcessedFile(gomock.Any(), "42", "etag", "").
+					Return(&model.ProcessedFile{AccountID: "42", Checksum: "abc", Summary: &model.Summary{
+						Credit: []model.Transaction{{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT}},
+					}}, nil)
+			},
+			want: want{
+				summary: &model.Summary{
+					Credit:           []model.Transaction{{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT}},
+					AlreadyProcessed: true,
+				},
+			},
+		},
+		{
+			name: "already_processed_checksum",
+			expectations: func() {
+				expectAccount()
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					DoAndReturn(openFile(requestRaw))
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					InsertTransactions(gomock.Any(), gomock.Any()).
+					Return(nil)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					FindProcessedFile(gomock.Any(), "42", "", gomock.Len(64)).
+					Return(&model.ProcessedFile{Summary: &model.Summary{}}, nil)
+			},
+			want: want{
+				summary: &model.Summary{AlreadyProcessed: true},
+			},
+		},
 		{
 			name: "error_records",
 			expectations: func() {
+				expectAccount()
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
 				procService.bucket.(*Mocks3Service).
 					EXPECT().
-					ReadFile(gomock.Any()).
-					Return(requestRaw, errors.New("fail"))
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					Return(nil, errors.New("fail"))
 			},
 			want: want{
 				summary: &model.Summary{},
 				err:     errors.New("fail"),
+				kind:    apperr.ErrSourceUnavailable,
+			},
+		},
+		{
+			name: "error_persistence",
+			expectations: func() {
+				expectAccount()
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					DoAndReturn(openFile(requestRaw))
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					InsertTransactions(gomock.Any(), gomock.Any()).
+					Return(errors.New("connection refused"))
+			},
+			want: want{
+				summary: &model.Summary{},
+				err:     errors.New("fail to insert transactions: connection refused"),
+				kind:    apperr.ErrPersistence,
+			},
+		},
+		{
+			name: "processed_concurrently",
+			expectations: func() {
+				expectAccount()
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					DoAndReturn(openFile(requestRaw))
+				expectNewChecksum()
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					InsertTransactions(gomock.Any(), gomock.Any()).
+					Return(nil)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					MarkProcessed(gomock.Any(), gomock.Any()).
+					Return(false, nil)
+			},
+			want: want{
+				summary: &model.Summary{
+					Credit: []model.Transaction{
+						{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)},
+						{AccountID: "42", ID: 3, Amount: model.NewMoney(1000, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.August, 13, 0, 0, 0, 0, time.UTC)}},
+					Debit: []model.Transaction{
+						{AccountID: "42", ID: 1, Amount: model.NewMoney(-1030, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.July, 28, 0, 0, 0, 0, time.UTC)},
+						{AccountID: "42", ID: 2, Amount: model.NewMoney(-2046, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.August, 2, 0, 0, 0, 0, time.UTC)}},
+					RunningBalance:   model.NewMoney(3974, "USD"),
+					AlreadyProcessed: true,
+				},
 			},
 		},
 		{
 			name: "ok",
 			expectations: func() {
+				expectAccount()
 				procService.bucket.(*Mocks3Service).
 					EXPECT().
-					ReadFile(gomock.Any()).
-					Return(requestRaw, nil)
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					DoAndReturn(openFile(requestRaw))
+				expectNewChecksum()
 				procService.email.(*MockemailService).
 					EXPECT().
 					SendEmail(gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, params model.EmailParams) {
+						assert.Equal(t, []string{owner.Email}, params.To)
+						assert.Equal(t, owner.Name, params.Payload.(model.Data).Name)
+						assert.True(t, params.Payload.(model.Data).StatementAttached)
+						require.Len(t, params.Attachments, 2)
+						assert.Equal(t, "application/pdf", params.Attachments[0].ContentType)
+					}).
 					Return(nil)
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
 				procService.repository.(*Mockrepository).
 					EXPECT().
 					InsertTransactions(gomock.Any(), gomock.Any()).
 					Return(nil).Times(1)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					MarkProcessed(gomock.Any(), gomock.Any()).
+					Return(true, nil)
 			},
 			want: want{
 				summary: &model.Summary{
-					Debit: []model.Transaction{
-						{ID: 0, Amount: 60.5, Date: time.Date(0, time.July, 15, 0, 0, 0, 0, time.UTC)},
-						{ID: 3, Amount: 10, Date: time.Date(0, time.August, 13, 0, 0, 0, 0, time.UTC)}},
 					Credit: []model.Transaction{
-						{ID: 1, Amount: -10.3, Date: time.Date(0, time.July, 28, 0, 0, 0, 0, time.UTC)},
-						{ID: 2, Amount: -20.46, Date: time.Date(0, time.August, 2, 0, 0, 0, 0, time.UTC)}},
-					RunningBalance: 39.74,
+						{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)},
+						{AccountID: "42", ID: 3, Amount: model.NewMoney(1000, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.August, 13, 0, 0, 0, 0, time.UTC)}},
+					Debit: []model.Transaction{
+						{AccountID: "42", ID: 1, Amount: model.NewMoney(-1030, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.July, 28, 0, 0, 0, 0, time.UTC)},
+						{AccountID: "42", ID: 2, Amount: model.NewMoney(-2046, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.August, 2, 0, 0, 0, 0, time.UTC)}},
+					RunningBalance: model.NewMoney(3974, "USD"),
 				},
 				err: nil,
 			},
 		},
+		{
+			name: "email_failure_rolls_back",
+			expectations: func() {
+				expectAccount()
+				procService.bucket.(*Mocks3Service).
+					EXPECT().
+					OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+					DoAndReturn(openFile(requestRaw))
+				expectNewChecksum()
+				procService.unitOfWork.(*MockunitOfWork).
+					EXPECT().
+					Run(gomock.Any(), gomock.Any()).
+					DoAndReturn(runInline)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					InsertTransactions(gomock.Any(), gomock.Any()).
+					Return(nil)
+				procService.repository.(*Mockrepository).
+					EXPECT().
+					MarkProcessed(gomock.Any(), gomock.Any()).
+					Return(true, nil)
+				procService.email.(*MockemailService).
+					EXPECT().
+					SendEmail(gomock.Any(), gomock.Any()).
+					Return(errors.New("throttling"))
+			},
+			want: want{err: errors.New("fail to send the summary email: throttling"), kind: apperr.ErrNotification},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.expectations()
-			summary, err := procService.ProcessCsv(context.Background())
+			summary, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+			if err != nil {
+				assert.Equal(t, tc.want.err.Error(), err.Error())
+				if tc.want.kind != nil {
+					assert.ErrorIs(t, err, tc.want.kind)
+				}
+				return
+			}
 
 			assert.ElementsMatch(t, tc.want.summary.Debit, summary.Debit)
 			assert.ElementsMatch(t, tc.want.summary.Credit, summary.Credit)
-			if err != nil {
-				assert.Equal(t, tc.want.err.Error(), err.Error())
+			assert.Equal(t, tc.want.summary.RunningBalance, summary.RunningBalance)
+			assert.Equal(t, tc.want.summary.AlreadyProcessed, summary.AlreadyProcessed)
+		})
+	}
+}
+
+// TestProcessCsvSchema processes a partner file with reordered and extra columns.
+func TestProcessCsvSchema(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	txsRepository := NewMockrepository(ctrl)
+	sesService := NewMockemailService(ctrl)
+	bucketService := NewMocks3Service(ctrl)
+	unitOfWork := NewMockunitOfWork(ctrl)
+	accountRepository := NewMockaccountRepository(ctrl)
+	procService := NewService(sesService, bucketService, txsRepository, unitOfWork, accountRepository, Config{})
+
+	file := "Amount,Merchant,Transaction Date (ISO-8601),Trx Id,Channel\n" +
+		"-10.3,Coffee Shop,2024-07-28,1,pos\n" +
+		"+60.5,,2024-07-15,0,transfer\n"
+
+	txsRepository.EXPECT().
+		FindProcessedFile(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, nil).Times(2)
+	bucketService.EXPECT().
+		Stat(gomock.Any(), "storicsv", "42/partner.csv").
+		Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{}}, nil)
+	bucketService.EXPECT().
+		OpenFile(gomock.Any(), "storicsv", "42/partner.csv").
+		DoAndReturn(openFile([]byte(file)))
+	accountRepository.EXPECT().
+		GetAccount(gomock.Any(), "42").
+		Return(&model.Account{ID: "42", Email: "julieta@example.com"}, nil)
+	sesService.EXPECT().
+		SendEmail(gomock.Any(), gomock.Any()).
+		Return(nil)
+
+	unitOfWork.EXPECT().
+		Run(gomock.Any(), gomock.Any()).
+		DoAndReturn(runInline)
+	txsRepository.EXPECT().
+		InsertTransactions(gomock.Any(), gomock.Any()).
+		Return(nil)
+	txsRepository.EXPECT().
+		MarkProcessed(gomock.Any(), gomock.Any()).
+		Return(true, nil)
+
+	summary, err := procService.ProcessCsv(context.Background(), "storicsv", "42/partner.csv")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Channel"}, summary.UnknownColumns)
+	assert.Equal(t, []model.Transaction{
+		{AccountID: "42", ID: 1, Amount: model.NewMoney(-1030, "USD"), Type: model.DEBIT, Date: time.Date(2024, time.July, 28, 0, 0, 0, 0, time.UTC), Merchant: "Coffee Shop"},
+	}, summary.Debit)
+	assert.Equal(t, []model.Transaction{
+		{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)},
+	}, summary.Credit)
+}
+
+// TestProcessCsvLenient processes the valid rows of a file and reports the rejected ones.
+func TestProcessCsvLenient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	file := "Id,Date,Transaction\n" +
+		"0,7/15,+60.5\n" +
+		"1,7/32,-10.3\n" +
+		"2,8/2,-20.4x\n" +
+		"3,8/13,+10\n"
+
+	tests := []struct {
+		name    string
+		lenient bool
+	}{
+		{name: "lenient", lenient: true},
+		{name: "strict", lenient: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			txsRepository := NewMockrepository(ctrl)
+			sesService := NewMockemailService(ctrl)
+			bucketService := NewMocks3Service(ctrl)
+			unitOfWork := NewMockunitOfWork(ctrl)
+			accountRepository := NewMockaccountRepository(ctrl)
+			procService := NewService(sesService, bucketService, txsRepository, unitOfWork, accountRepository, Config{Lenient: tc.lenient})
+
+			lookups := 1
+			if tc.lenient {
+				lookups = 2
+			}
+			txsRepository.EXPECT().
+				FindProcessedFile(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, nil).Times(lookups)
+			unitOfWork.EXPECT().
+				Run(gomock.Any(), gomock.Any()).
+				DoAndReturn(runInline)
+			bucketService.EXPECT().
+				Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+				Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{PeriodMetadataKey: "2024-08"}}, nil)
+			bucketService.EXPECT().
+				OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+				DoAndReturn(openFile([]byte(file)))
+			accountRepository.EXPECT().
+				GetAccount(gomock.Any(), "42").
+				Return(&model.Account{ID: "42", Email: "julieta@example.com"}, nil)
+
+			if !tc.lenient {
+				_, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+				var rowErr *RowError
+				assert.True(t, errors.As(err, &rowErr))
+				return
+			}
+
+			bucketService.EXPECT().
+				WriteFile(gomock.Any(), "storicsv", "42/transactions.errors.csv", gomock.Any(), nil).
+				Do(func(_ context.Context, _, _ string, body []byte, _ map[string]string) {
+					assert.Equal(t, "line,column,value,reason\n"+
+						"3,date,7/32,\"\"\"7/32\"\" is not M/D: invalid date\"\n"+
+						"4,amount,-20.4x,\"\"\"-20.4x\"\": invalid money amount\"\n", string(body))
+				}).
+				Return(nil)
+			sesService.EXPECT().
+				SendEmail(gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, params model.EmailParams) {
+					assert.Equal(t, 2, params.Payload.(model.Data).Rejected)
+				}).
+				Return(nil)
+			txsRepository.EXPECT().
+				InsertTransactions(gomock.Any(), gomock.Any()).
+				Return(nil)
+			txsRepository.EXPECT().
+				MarkProcessed(gomock.Any(), gomock.Any()).
+				Return(true, nil)
+
+			summary, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+
+			assert.NoError(t, err)
+			assert.Equal(t, 2, summary.Rejected)
+			assert.Equal(t, "42/transactions.errors.csv", summary.ErrorReport)
+			assert.Len(t, summary.Credit, 2)
+			assert.Equal(t, model.NewMoney(7050, "USD"), summary.RunningBalance)
+		})
+	}
+}
+
+// TestProcessCsvErrorReport uploads the report of the rejected rows once the file is committed, a rolled back
+// file leaves no report behind.
+func TestProcessCsvErrorReport(t *testing.T) {
+	file := "Id,Date,Transaction\n0,7/15,+60.5\n1,7/32,-10.3\n"
+
+	tests := []struct {
+		name      string
+		markErr   error
+		wantSteps []string
+	}{
+		{name: "committed", wantSteps: []string{"commit", "report"}},
+		{name: "rolled_back", markErr: errors.New("connection reset"), wantSteps: []string{"rollback"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			txsRepository := NewMockrepository(ctrl)
+			sesService := NewMockemailService(ctrl)
+			bucketService := NewMocks3Service(ctrl)
+			unitOfWork := NewMockunitOfWork(ctrl)
+			accountRepository := NewMockaccountRepository(ctrl)
+			procService := NewService(sesService, bucketService, txsRepository, unitOfWork, accountRepository, Config{Lenient: true})
+
+			var steps []string
+			bucketService.EXPECT().
+				Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+				Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{PeriodMetadataKey: "2024-08"}}, nil)
+			accountRepository.EXPECT().
+				GetAccount(gomock.Any(), "42").
+				Return(&model.Account{ID: "42", Email: "julieta@example.com"}, nil)
+			txsRepository.EXPECT().
+				FindProcessedFile(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, nil).Times(2)
+			unitOfWork.EXPECT().
+				Run(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+					err := fn(ctx)
+					if err != nil {
+						steps = append(steps, "rollback")
+					} else {
+						steps = append(steps, "commit")
+					}
+					return err
+				})
+			bucketService.EXPECT().
+				OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+				DoAndReturn(openFile([]byte(file)))
+			txsRepository.EXPECT().
+				InsertTransactions(gomock.Any(), gomock.Any()).
+				Return(nil)
+			txsRepository.EXPECT().
+				MarkProcessed(gomock.Any(), gomock.Any()).
+				Return(tc.markErr == nil, tc.markErr)
+			if tc.markErr == nil {
+				sesService.EXPECT().SendEmail(gomock.Any(), gomock.Any()).Return(nil)
+				bucketService.EXPECT().
+					WriteFile(gomock.Any(), "storicsv", "42/transactions.errors.csv", gomock.Any(), nil).
+					Do(func(context.Context, string, string, []byte, map[string]string) { steps = append(steps, "report") }).
+					Return(nil)
+			}
+
+			summary, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+
+			assert.Equal(t, tc.wantSteps, steps)
+			if tc.markErr != nil {
+				assert.ErrorIs(t, err, tc.markErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "42/transactions.errors.csv", summary.ErrorReport)
+		})
+	}
+}
+
+// TestProcessCsvRedactsRows fails when the content of a rejected row reaches the logs or the error answered
+// to clients.
+func TestProcessCsvRedactsRows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.DebugLevel)
+
+	txsRepository := NewMockrepository(ctrl)
+	bucketService := NewMocks3Service(ctrl)
+	unitOfWork := NewMockunitOfWork(ctrl)
+	accountRepository := NewMockaccountRepository(ctrl)
+	procService := NewService(NewMockemailService(ctrl), bucketService, txsRepository, unitOfWork, accountRepository, Config{})
+
+	bucketService.EXPECT().
+		Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+		Return(&model.FileInfo{ETag: "etag"}, nil)
+	accountRepository.EXPECT().
+		GetAccount(gomock.Any(), "42").
+		Return(&model.Account{ID: "42", Email: "julieta@example.com"}, nil)
+	txsRepository.EXPECT().
+		FindProcessedFile(gomock.Any(), "42", "etag", "").
+		Return(nil, nil)
+	unitOfWork.EXPECT().
+		Run(gomock.Any(), gomock.Any()).
+		DoAndReturn(runInline)
+	bucketService.EXPECT().
+		OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+		DoAndReturn(openFile([]byte("Id,Date,Amount,Type\n0,7/15,-1234.56,credit\n")))
+
+	_, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+
+	require.Error(t, err)
+	for _, output := range []string{out.String(), err.Error(), apperr.MessageOf(err)} {
+		assert.NotContains(t, output, "1234.56")
+		assert.NotContains(t, output, "julieta@")
+	}
+	assert.Equal(t, "line 2: invalid type", apperr.MessageOf(err))
+}
+
+// TestProcessCsvStress streams a large file through the worker pool, it is meant to be executed with -race.
+func TestProcessCsvStress(t *testing.T) {
+	const (
+		rows      = 100000
+		batchSize = 1000
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	txsRepository := NewMockrepository(ctrl)
+	sesService := NewMockemailService(ctrl)
+	bucketService := NewMocks3Service(ctrl)
+	unitOfWork := NewMockunitOfWork(ctrl)
+	accountRepository := NewMockaccountRepository(ctrl)
+	procService := NewService(sesService, bucketService, txsRepository, unitOfWork, accountRepository, Config{BatchSize: batchSize})
+	procService.now = func() time.Time { return time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC) }
+
+	// rows are written in reverse id order so the order of the summary can only come from the aggregate
+	var file bytes.Buffer
+	file.WriteString("Id,Date,Transaction\n")
+	for id := rows - 1; id >= 0; id-- {
+		amount := "+1.25"
+		if id%2 == 1 {
+			amount = "-0.75"
+		}
+		fmt.Fprintf(&file, "%d,%d/%d,%s\n", id, id%12+1, id%28+1, amount)
+	}
+
+	txsRepository.EXPECT().
+		FindProcessedFile(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, nil).Times(4)
+	bucketService.EXPECT().
+		Stat(gomock.Any(), "storicsv", "42/transactions.csv").
+		Return(&model.FileInfo{ETag: "etag", Metadata: map[string]string{}}, nil).Times(2)
+	bucketService.EXPECT().
+		OpenFile(gomock.Any(), "storicsv", "42/transactions.csv").
+		DoAndReturn(openFile(file.Bytes())).Times(2)
+	accountRepository.EXPECT().
+		GetAccount(gomock.Any(), "42").
+		Return(&model.Account{ID: "42", Email: "julieta@example.com"}, nil).Times(2)
+	sesService.EXPECT().
+		SendEmail(gomock.Any(), gomock.Any()).
+		Return(nil).Times(2)
+
+	// batches are inserted by a single collector, so the counters need no lock
+	inserted := map[float64]int{}
+	txsRepository.EXPECT().
+		InsertTransactions(gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, transactions []model.Transaction) {
+			assert.Len(t, transactions, batchSize)
+			for _, transaction := range transactions {
+				inserted[transaction.ID]++
+			}
+		}).
+		Return(nil).Times(2 * rows / batchSize)
+	txsRepository.EXPECT().
+		MarkProcessed(gomock.Any(), gomock.Any()).
+		Return(true, nil).Times(2)
+	unitOfWork.EXPECT().
+		Run(gomock.Any(), gomock.Any()).
+		DoAndReturn(runInline).Times(2)
+
+	first, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+	assert.NoError(t, err)
+	second, err := procService.ProcessCsv(context.Background(), "storicsv", "42/transactions.csv")
+	assert.NoError(t, err)
+
+	assert.Equal(t, rows/2, first.DebitCount)
+	assert.Equal(t, rows/2, first.CreditCount)
+	assert.Equal(t, model.NewMoney(2500000, "USD"), first.RunningBalance)
+	assert.Equal(t, model.NewMoney(125, "USD"), first.GetAverage(model.CREDIT))
+	assert.Equal(t, model.NewMoney(-75, "USD"), first.GetAverage(model.DEBIT))
+	assert.True(t, first.Truncated)
+	assert.Equal(t, first, second)
+
+	// the summary lists the lowest ids
+	assert.Len(t, first.Credit, DefaultSummaryLimit/2)
+	for i := range first.Credit {
+		assert.Equal(t, float64(2*i), first.Credit[i].ID)
+	}
+
+	assert.Len(t, inserted, rows)
+	for id, count := range inserted {
+		if count != 2 {
+			t.Fatalf("transaction %v inserted %d times", id, count)
+		}
+	}
+}
+
+// TestSummaryEmail attaches the statement unless it is over the limit or truncated, then it links to it.
+func TestSummaryEmail(t *testing.T) {
+	owner := &model.Account{ID: "42", Name: "Julieta", Email: "julieta@example.com"}
+	summary := &model.Summary{
+		ID:        "abc",
+		AccountID: "42",
+		Currency:  "USD",
+		Credit: []model.Transaction{
+			{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC)},
+		},
+		CreditCount: 1,
+	}
+	truncated := *summary
+	truncated.CreditCount = 2
+	truncated.Truncated = true
+
+	tests := []struct {
+		name     string
+		config   Config
+		summary  *model.Summary
+		attached bool
+		url      string
+	}{
+		{name: "attached", summary: summary, attached: true},
+		{name: "over the limit", config: Config{AttachmentLimit: 100, StatementURL: "https://stori.com/statements/{id}"}, summary: summary, url: "https://stori.com/statements/abc"},
+		{name: "truncated", config: Config{StatementURL: "https://stori.com/statements/{id}"}, summary: &truncated, url: "https://stori.com/statements/abc"},
+		{name: "no link", config: Config{AttachmentLimit: 100}, summary: summary},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			procService := NewService(nil, nil, nil, nil, nil, tc.config)
+
+			params := procService.summaryEmail(context.Background(), owner, tc.summary)
+
+			data := params.Payload.(model.Data)
+			assert.Equal(t, tc.attached, data.StatementAttached)
+			assert.Equal(t, tc.url, data.StatementURL)
+			if tc.attached {
+				assert.Len(t, params.Attachments, 2)
+			} else {
+				assert.Empty(t, params.Attachments)
+			}
+		})
+	}
+}
+
+// TestListTransactions returns the history in the currency of the account with a bounded page.
+func TestListTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	txsRepository := NewMockrepository(ctrl)
+	accountRepository := NewMockaccountRepository(ctrl)
+	procService := NewService(nil, nil, txsRepository, nil, accountRepository, Config{})
+
+	accountRepository.EXPECT().
+		GetAccount(gomock.Any(), "42").
+		Return(&model.Account{ID: "42", Currency: "JPY"}, nil)
+	txsRepository.EXPECT().
+		ListTransactions(gomock.Any(), "42", TransactionFilter{Page: 1, PageSize: MaxPageSize}).
+		Return([]model.Transaction{{AccountID: "42", ID: 1, Amount: model.NewMoney(150000, "")}}, nil)
+
+	transactions, err := procService.ListTransactions(context.Background(), "42", TransactionFilter{PageSize: 1000})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Transaction{{AccountID: "42", ID: 1, Amount: model.NewMoney(1500, "JPY")}}, transactions)
+}
+
+// TestProcessRecordType pins the type of a row: the sign decides unless the file has a type column,
+// which signs unsigned amounts.
+func TestProcessRecordType(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   []string
+		fields   []string
+		wantType model.TransactionType
+		amount   model.Money
+		reason   string
+	}{
+		{
+			name:     "negative_amount_is_debit",
+			header:   []string{"Id", "Date", "Amount"},
+			fields:   []string{"1", "2024-07-28", "-10.3"},
+			wantType: model.DEBIT,
+			amount:   model.NewMoney(-1030, "USD"),
+		},
+		{
+			name:     "positive_amount_is_credit",
+			header:   []string{"Id", "Date", "Amount"},
+			fields:   []string{"1", "2024-07-28", "+60.5"},
+			wantType: model.CREDIT,
+			amount:   model.NewMoney(6050, "USD"),
+		},
+		{
+			name:     "type_column_signs_debit",
+			header:   []string{"Id", "Date", "Amount", "Type"},
+			fields:   []string{"1", "2024-07-28", "10.3", "DR"},
+			wantType: model.DEBIT,
+			amount:   model.NewMoney(-1030, "USD"),
+		},
+		{
+			name:     "empty_type_uses_sign",
+			header:   []string{"Id", "Date", "Amount", "Type"},
+			fields:   []string{"1", "2024-07-28", "-10.3", ""},
+			wantType: model.DEBIT,
+			amount:   model.NewMoney(-1030, "USD"),
+		},
+		{
+			name:   "negative_credit",
+			header: []string{"Id", "Date", "Amount", "Type"},
+			fields: []string{"1", "2024-07-28", "-10.3", "credit"},
+			reason: "credit with the negative amount -10.3",
+		},
+		{
+			name:   "unknown_type",
+			header: []string{"Id", "Date", "Amount", "Type"},
+			fields: []string{"1", "2024-07-28", "10.3", "refund"},
+			reason: "\"refund\": invalid transaction type",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := csvschema.Default().Map(tc.header)
+			require.NoError(t, err)
+			parser := &rowParser{
+				accountID: "42",
+				currency:  "USD",
+				dates:     dateparse.NewParser(dateparse.ISO, time.Time{}),
+				mapping:   mapping,
+			}
+
+			transaction, err := parser.processRecord(context.Background(), row{line: 2, fields: tc.fields})
+
+			if tc.reason != "" {
+				var rowErr *RowError
+				require.ErrorAs(t, err, &rowErr)
+				assert.Equal(t, tc.reason, rowErr.Reason)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantType, transaction.Type)
+			assert.Equal(t, tc.amount, transaction.Amount)
+		})
+	}
+}
+
+// TestProcessRecordID rejects ids the integer id column cannot store as they are.
+func TestProcessRecordID(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		want   float64
+		reason string
+	}{
+		{name: "integer", id: "7", want: 7},
+		{name: "whole_decimal", id: "7.0", want: 7},
+		{name: "fractional", id: "2.5", reason: "must be an integer between -2147483648 and 2147483647"},
+		{name: "out_of_range", id: "3000000000", reason: "must be an integer between -2147483648 and 2147483647"},
+		{name: "not_a_number", id: "NaN", reason: "must be an integer between -2147483648 and 2147483647"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := csvschema.Default().Map([]string{"Id", "Date", "Amount"})
+			require.NoError(t, err)
+			parser := &rowParser{
+				accountID: "42",
+				currency:  "USD",
+				dates:     dateparse.NewParser(dateparse.ISO, time.Time{}),
+				mapping:   mapping,
+			}
+
+			transaction, err := parser.processRecord(context.Background(), row{line: 2, fields: []string{tc.id, "2024-07-28", "-10.3"}})
+
+			if tc.reason != "" {
+				var rowErr *RowError
+				require.ErrorAs(t, err, &rowErr)
+				assert.Equal(t, tc.reason, rowErr.Reason)
+				return
 			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, transaction.ID)
 		})
 	}
 }
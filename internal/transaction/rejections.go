@@ -0,0 +1,72 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxReportedRows bounds the rows listed in an error report, the rest are only counted.
+const maxReportedRows = 100000
+
+// errorReportSuffix is appended to the name of the input to build the key of its error report.
+const errorReportSuffix = ".errors.csv"
+
+// RowError describes why a row of the file was rejected. The value and the reason, which usually quotes it,
+// are only listed in the error report: Error leaves them out because errors are logged and answered to clients.
+type RowError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("line %d: invalid %s", e.Line, e.Column)
+}
+
+func newRowError(line int, column, value string, err error) *RowError {
+	return &RowError{Line: line, Column: column, Value: value, Reason: err.Error()}
+}
+
+// ErrorReportKey returns the key of the error report written next to the input,
+// e.g. 42/2024-01-31.csv is reported in 42/2024-01-31.errors.csv.
+func ErrorReportKey(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + errorReportSuffix
+}
+
+// IsErrorReport tells whether the key belongs to an error report, reports are uploaded to the same
+// bucket as the statements and must not be processed as one.
+func IsErrorReport(key string) bool {
+	return strings.HasSuffix(key, errorReportSuffix)
+}
+
+// buildErrorReport renders the rejected rows as csv, omitted rows rejected past maxReportedRows are
+// mentioned in a last row without line.
+func buildErrorReport(rejected []*RowError, omitted int) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	if err := writer.Write([]string{"line", "column", "value", "reason"}); err != nil {
+		return nil, err
+	}
+	for _, row := range rejected {
+		if err := writer.Write([]string{strconv.Itoa(row.Line), row.Column, row.Value, row.Reason}); err != nil {
+			return nil, err
+		}
+	}
+	if omitted > 0 {
+		if err := writer.Write([]string{"", "", "", fmt.Sprintf("%d more rows rejected", omitted)}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+
+	return buf.Bytes(), writer.Error()
+}
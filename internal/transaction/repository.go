@@ -3,27 +3,127 @@ package transaction
 import (
 	"context"
 	"github.com/go-pg/pg/v10/orm"
+	log "github.com/sirupsen/logrus"
 	"stori-challenge/internal/integrations/db"
 	"stori-challenge/internal/model"
+	"time"
 )
 
 type Repository struct {
-	db orm.DB
+	db     orm.DB
+	config InsertConfig
 }
 
-func NewRepository(db orm.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db orm.DB, config InsertConfig) *Repository {
+	return &Repository{db: db, config: config}
 }
 
+// InsertTransactions upserts the transactions by their natural key (account, external id),
+// so ingesting the same rows twice never duplicates the history. They are written in batches of
+// InsertConfig.BatchSize with the configured strategy, a failing batch is reported as a *BatchError.
 func (r *Repository) InsertTransactions(ctx context.Context, transactions []model.Transaction) error {
-	if len(transactions) == 0 {
-		return nil
+	transactions = latestByKey(transactions)
+
+	insert := r.insertBatch
+	if r.config.Strategy == InsertCopy {
+		insert = r.copyBatch
 	}
 
-	database := db.GetConnection(ctx, r.db)
-	if _, err := database.ModelContext(ctx, &transactions).Insert(); err != nil {
-		return err
+	size := r.config.batchSize()
+	for batch, offset := 0, 0; offset < len(transactions); batch, offset = batch+1, offset+size {
+		end := offset + size
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		if err := insert(ctx, transactions[offset:end]); err != nil {
+			batchErr := &BatchError{Batch: batch, Offset: offset, Size: end - offset, Err: err}
+			log.WithContext(ctx).
+				WithFields(log.Fields{"event": "insert_transactions", "strategy": r.config.Strategy}).
+				Error(batchErr)
+			return batchErr
+		}
 	}
 
 	return nil
 }
+
+// FindProcessedFile returns the file of the account matching the etag or the checksum, empty values are
+// ignored. It returns nil when the account never processed the file.
+func (r *Repository) FindProcessedFile(ctx context.Context, accountID, etag, checksum string) (*model.ProcessedFile, error) {
+	if etag == "" && checksum == "" {
+		return nil, nil
+	}
+
+	var files []model.ProcessedFile
+	database := db.GetConnection(ctx, r.db)
+	query := database.ModelContext(ctx, &files).
+		Where("account_id = ?", accountID).
+		WhereGroup(func(query *orm.Query) (*orm.Query, error) {
+			if checksum != "" {
+				query = query.WhereOr("checksum = ?", checksum)
+			}
+			if etag != "" {
+				query = query.WhereOr("etag = ?", etag)
+			}
+			return query, nil
+		}).
+		Limit(1)
+
+	if err := query.Select(); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	return &files[0], nil
+}
+
+// MarkProcessed records the file, it returns false when another invocation already recorded the same checksum
+// for the account.
+func (r *Repository) MarkProcessed(ctx context.Context, file *model.ProcessedFile) (bool, error) {
+	database := db.GetConnection(ctx, r.db)
+	result, err := database.ModelContext(ctx, file).
+		OnConflict("(account_id, checksum) DO NOTHING").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// TransactionFilter selects a page of the history of an account, zero dates leave the range open.
+type TransactionFilter struct {
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// ListTransactions returns the transactions of the account dated between From and To (both inclusive)
+// ordered by date and id.
+func (r *Repository) ListTransactions(ctx context.Context, accountID string, filter TransactionFilter) ([]model.Transaction, error) {
+	var transactions []model.Transaction
+
+	database := db.GetConnection(ctx, r.db)
+	query := database.ModelContext(ctx, &transactions).Where("account_id = ?", accountID)
+	if !filter.From.IsZero() {
+		query = query.Where("date >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("date <= ?", filter.To)
+	}
+
+	err := query.
+		Order("date ASC", "id ASC").
+		Limit(filter.PageSize).
+		Offset((filter.Page - 1) * filter.PageSize).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
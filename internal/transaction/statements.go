@@ -0,0 +1,157 @@
+package transaction
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/model"
+	"stori-challenge/internal/statement"
+)
+
+// Page sizes of the transaction history.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+var ErrStatementNotFound = errors.New("statement not found")
+
+// Upload stores the file under key in the bucket and processes it, metadata carries the account,
+// source and statement period of the file.
+func (s *Service) Upload(ctx context.Context, bucket, key string, body []byte, metadata map[string]string) (*model.Summary, error) {
+	if err := s.bucket.WriteFile(ctx, bucket, key, body, metadata); err != nil {
+		return nil, apperr.Wrap(apperr.ErrSourceUnavailable, errors.Wrap(err, "failed to store the file"))
+	}
+
+	return s.ProcessCsv(ctx, bucket, key)
+}
+
+// GetStatement returns the stored summary of a processed file, id is its model.StatementID.
+func (s *Service) GetStatement(ctx context.Context, id string) (*model.Summary, error) {
+	processed, err := s.findStatement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return statementSummary(processed, id), nil
+}
+
+// findStatement looks up the processed file of the statement id.
+func (s *Service) findStatement(ctx context.Context, id string) (*model.ProcessedFile, error) {
+	accountID, checksum, ok := model.ParseStatementID(id)
+	if !ok {
+		return nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrapf(ErrStatementNotFound, "statement %s", id))
+	}
+
+	processed, err := s.repository.FindProcessedFile(ctx, accountID, "", checksum)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+	if processed == nil {
+		return nil, apperr.Wrap(apperr.ErrNotFound, errors.Wrapf(ErrStatementNotFound, "statement %s", id))
+	}
+
+	return processed, nil
+}
+
+// statementSummary is the stored summary of the processed file, identified as id.
+func statementSummary(processed *model.ProcessedFile, id string) *model.Summary {
+	summary := processed.Summary
+	if summary == nil {
+		summary = &model.Summary{AccountID: processed.AccountID}
+	}
+	summary.ID = id
+
+	return summary
+}
+
+// StatementFile renders the stored statement as a "pdf" or "csv" file for the owner of the account, it lists
+// every transaction of the processed file, like the statement attached to the summary email.
+func (s *Service) StatementFile(ctx context.Context, id, format string) (*model.Attachment, error) {
+	processed, err := s.findStatement(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	summary := statementSummary(processed, id)
+
+	owner, err := s.accounts.GetAccount(ctx, summary.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.statementTransactions(ctx, processed, summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return statement.Render(format, *owner, summary, transactions)
+}
+
+// statementTransactions lists the transactions of the processed file. The stored summary only lists up to
+// Config.SummaryLimit of them, a truncated one reads the file again with the date it was processed on, so
+// the years are inferred as they were. The history of the account is not used, it holds the rows of every
+// file of the account and a row of this file may have been replaced by a later file since.
+func (s *Service) statementTransactions(ctx context.Context, processed *model.ProcessedFile, summary *model.Summary) ([]model.Transaction, error) {
+	if !summary.Truncated {
+		return statement.Transactions(summary), nil
+	}
+
+	info, err := s.bucket.Stat(ctx, processed.Bucket, processed.Key)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.ErrSourceUnavailable, err)
+	}
+	processedAt := processed.ProcessedAt
+	if processedAt.IsZero() {
+		processedAt = s.now()
+	}
+
+	read := &model.Summary{AccountID: summary.AccountID, Currency: summary.Currency}
+	result, checksum, err := s.stream(ctx, processed.Bucket, processed.Key, info.Metadata, read, reading{
+		now:     processedAt,
+		limit:   summary.DebitCount + summary.CreditCount,
+		lenient: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if checksum != processed.Checksum {
+		return nil, apperr.Wrap(apperr.ErrNotFound,
+			errors.Wrapf(ErrStatementNotFound, "the file of statement %s was replaced", summary.ID))
+	}
+	result.summarize(read)
+
+	return statement.Transactions(read), nil
+}
+
+// ListTransactions returns a page of the history of the account in the currency of the account.
+func (s *Service) ListTransactions(ctx context.Context, accountID string, filter TransactionFilter) ([]model.Transaction, error) {
+	owner, err := s.accounts.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = DefaultPageSize
+	}
+	if filter.PageSize > MaxPageSize {
+		filter.PageSize = MaxPageSize
+	}
+
+	transactions, err := s.repository.ListTransactions(ctx, owner.ID, filter)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.ErrPersistence, err)
+	}
+
+	// numeric columns are scanned in the default currency
+	for i := range transactions {
+		transactions[i].Amount, err = transactions[i].Amount.WithCurrency(owner.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transactions, nil
+}
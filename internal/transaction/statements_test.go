@@ -0,0 +1,172 @@
+package transaction
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"stori-challenge/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetStatement(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repository := NewMockrepository(ctrl)
+	service := NewService(nil, nil, repository, nil, nil, Config{})
+
+	tests := []struct {
+		name         string
+		id           string
+		expectations func()
+		want         *model.Summary
+		err          error
+	}{
+		{
+			name: "scoped_to_the_account",
+			id:   model.StatementID("acc-42", "abc"),
+			expectations: func() {
+				repository.EXPECT().
+					FindProcessedFile(gomock.Any(), "acc-42", "", "abc").
+					Return(&model.ProcessedFile{AccountID: "acc-42", Checksum: "abc", Summary: &model.Summary{AccountID: "acc-42"}}, nil)
+			},
+			want: &model.Summary{ID: "acc-42-abc", AccountID: "acc-42"},
+		},
+		{
+			name: "same_file_of_another_account",
+			id:   model.StatementID("7", "abc"),
+			expectations: func() {
+				repository.EXPECT().FindProcessedFile(gomock.Any(), "7", "", "abc").Return(nil, nil)
+			},
+			err: ErrStatementNotFound,
+		},
+		{
+			name:         "bare_checksum",
+			id:           "abc",
+			expectations: func() {},
+			err:          ErrStatementNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.expectations()
+
+			summary, err := service.GetStatement(context.Background(), tc.id)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, summary)
+		})
+	}
+}
+
+// TestStatementFile lists the transactions of the processed file, another file of the account in the same
+// month is left out even when the summary is truncated and the file is read again.
+func TestStatementFile(t *testing.T) {
+	files := map[string]string{
+		"a.csv": "Id,Date,Transaction\n0,7/15,+60.5\n1,7/28,-10.3\n2,7/30,+5\n",
+		"b.csv": "Id,Date,Transaction\n3,7/2,+20\n4,7/20,-1.5\n",
+	}
+	checksum := func(key string) string {
+		sum := sha256.Sum256([]byte(files[key]))
+		return hex.EncodeToString(sum[:])
+	}
+	july := func(day int) time.Time { return time.Date(2024, time.July, day, 0, 0, 0, 0, time.UTC) }
+	credit := model.Transaction{AccountID: "42", ID: 0, Amount: model.NewMoney(6050, "USD"), Type: model.CREDIT, Date: july(15)}
+
+	tests := []struct {
+		name     string
+		key      string
+		checksum string
+		summary  *model.Summary
+		read     bool
+		ids      []string
+		err      error
+	}{
+		{
+			name:     "listed_in_the_summary",
+			key:      "a.csv",
+			checksum: checksum("a.csv"),
+			summary:  &model.Summary{AccountID: "42", Currency: "USD", Credit: []model.Transaction{credit}, CreditCount: 1},
+			ids:      []string{"0"},
+		},
+		{
+			name:     "truncated",
+			key:      "a.csv",
+			checksum: checksum("a.csv"),
+			summary:  &model.Summary{AccountID: "42", Currency: "USD", Credit: []model.Transaction{credit}, CreditCount: 2, DebitCount: 1, Truncated: true},
+			read:     true,
+			ids:      []string{"0", "1", "2"},
+		},
+		{
+			name:     "other_file_of_the_month",
+			key:      "b.csv",
+			checksum: checksum("b.csv"),
+			summary:  &model.Summary{AccountID: "42", Currency: "USD", CreditCount: 1, DebitCount: 1, Truncated: true},
+			read:     true,
+			ids:      []string{"3", "4"},
+		},
+		{
+			name:     "replaced_file",
+			key:      "b.csv",
+			checksum: checksum("a.csv"),
+			summary:  &model.Summary{AccountID: "42", Currency: "USD", CreditCount: 2, DebitCount: 1, Truncated: true},
+			read:     true,
+			err:      ErrStatementNotFound,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repository := NewMockrepository(ctrl)
+			accounts := NewMockaccountRepository(ctrl)
+			bucket := NewMocks3Service(ctrl)
+			service := NewService(nil, bucket, repository, nil, accounts, Config{SummaryLimit: 1})
+
+			repository.EXPECT().
+				FindProcessedFile(gomock.Any(), "42", "", tc.checksum).
+				Return(&model.ProcessedFile{
+					AccountID:   "42",
+					Checksum:    tc.checksum,
+					Bucket:      "stori",
+					Key:         tc.key,
+					Summary:     tc.summary,
+					ProcessedAt: time.Date(2024, time.August, 1, 10, 0, 0, 0, time.UTC),
+				}, nil)
+			accounts.EXPECT().
+				GetAccount(gomock.Any(), "42").
+				Return(&model.Account{ID: "42", Currency: "USD"}, nil)
+			if tc.read {
+				bucket.EXPECT().Stat(gomock.Any(), "stori", tc.key).Return(&model.FileInfo{}, nil)
+				bucket.EXPECT().OpenFile(gomock.Any(), "stori", tc.key).Return(io.NopCloser(strings.NewReader(files[tc.key])), nil)
+			}
+
+			file, err := service.StatementFile(context.Background(), model.StatementID("42", tc.checksum), "csv")
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			rows := strings.Split(strings.TrimSuffix(string(file.Data), "\n"), "\n")[1:]
+			ids := make([]string, len(rows))
+			for i, row := range rows {
+				ids[i] = strings.Split(row, ",")[0]
+				assert.Contains(t, row, ",2024-07-", row)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package transaction
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"stori-challenge/internal/apperr"
+	"stori-challenge/internal/model"
+	"sync"
+	"time"
+)
+
+const numWorkers = 5
+
+// row is a record of the file along with its line number, err holds the csv error of a malformed line.
+type row struct {
+	line   int
+	fields []string
+	err    error
+}
+
+// parsed is the outcome of a row, either a transaction or the error that rejected it.
+type parsed struct {
+	transaction model.Transaction
+	err         error
+}
+
+// readRow reads the next record, malformed lines are returned as rows so they can be rejected.
+func readRow(reader *csv.Reader) (row, error) {
+	fields, err := reader.Read()
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return row{line: parseErr.StartLine, fields: fields, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return row{}, err
+	}
+
+	line, _ := reader.FieldPos(0)
+	return row{line: line, fields: fields}, nil
+}
+
+// pipeline keeps the first error of any stage and cancels the others.
+type pipeline struct {
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+}
+
+func (p *pipeline) fail(err error) {
+	p.once.Do(func() {
+		p.err = err
+		p.cancel()
+	})
+}
+
+// reading holds what differs between processing a file and reading it again to render its statement.
+type reading struct {
+	// now is the date the years missing in the file are inferred from when it names no statement period.
+	now time.Time
+	// limit is the number of transactions listed by the aggregate.
+	limit   int
+	lenient bool
+	// insert receives the transactions in batches of Config.BatchSize, nil reads without storing them.
+	insert func(context.Context, []model.Transaction) error
+}
+
+// ingest streams the file through the worker pool, inserts its transactions and returns its aggregate and
+// the SHA-256 checksum of its content.
+func (s *Service) ingest(ctx context.Context, bucket, key string, metadata map[string]string, summary *model.Summary) (
+	*aggregate,
+	string,
+	error,
+) {
+	return s.stream(ctx, bucket, key, metadata, summary, reading{
+		now:     s.now(),
+		limit:   s.config.summaryLimit(),
+		lenient: s.config.Lenient,
+		insert:  s.repository.InsertTransactions,
+	})
+}
+
+// stream reads the file through the worker pool and returns its aggregate and the SHA-256 checksum of its
+// content. A single reader feeds the workers through an unbuffered channel, so reading waits for them, and
+// a single collector folds their results and hands the transactions to insert in batches of
+// Config.BatchSize, which in turn slows the workers down while the database is busy.
+func (s *Service) stream(ctx context.Context, bucket, key string, metadata map[string]string, summary *model.Summary, options reading) (
+	*aggregate,
+	string,
+	error,
+) {
+	body, err := s.bucket.OpenFile(ctx, bucket, key)
+	if err != nil {
+		log.WithContext(ctx).
+			WithFields(log.Fields{"event": "records_from_file"}).
+			Errorf("failed to get transactions %s", err.Error())
+		return nil, "", apperr.Wrap(apperr.ErrSourceUnavailable, err)
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(body, hash))
+	reader.Comma = ','
+	reader.FieldsPerRecord = -1
+
+	first, err := readRow(reader)
+	if err != nil && err != io.EOF {
+		return nil, "", apperr.Wrap(apperr.ErrSourceUnavailable, err)
+	}
+	empty := err == io.EOF
+
+	mapping, err := s.getMapping(ctx, metadata, first.fields)
+	if err != nil {
+		return nil, "", apperr.InvalidRow(err)
+	}
+	summary.UnknownColumns = mapping.Unknown
+
+	dates, err := s.getDateParser(metadata, mapping, options.now)
+	if err != nil {
+		return nil, "", apperr.InvalidRow(err)
+	}
+	parser := &rowParser{accountID: summary.AccountID, currency: summary.Currency, dates: dates, mapping: mapping}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := &pipeline{cancel: cancel}
+
+	records := make(chan row)
+	results := make(chan parsed, numWorkers)
+
+	// read: the header is skipped, a headerless file starts with a transaction
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer close(records)
+
+		if !empty && !mapping.HasHeader {
+			select {
+			case records <- first:
+			case <-workerCtx.Done():
+				return
+			}
+		}
+		for !empty {
+			record, err := readRow(reader)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				p.fail(apperr.Wrap(apperr.ErrSourceUnavailable, errors.Wrap(err, "failed to read the file")))
+				return
+			}
+			select {
+			case records <- record:
+			case <-workerCtx.Done():
+				return
+			}
+		}
+	}()
+
+	// map: the workers only parse, the first failure stops the pipeline
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for record := range records {
+				transaction, err := parser.processRecord(workerCtx, record)
+				select {
+				case results <- parsed{transaction: transaction, err: err}:
+				case <-workerCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// reduce: a single collector owns the aggregate and the pending batch
+	result := newAggregate(summary.Currency, options.limit, maxReportedRows)
+	batch := make([]model.Transaction, 0, s.config.batchSize())
+	flush := func() error {
+		if len(batch) == 0 || options.insert == nil {
+			batch = batch[:0]
+			return nil
+		}
+		if err := options.insert(ctx, batch); err != nil {
+			return apperr.Wrap(apperr.ErrPersistence, errors.Wrap(err, "fail to insert transactions"))
+		}
+		batch = make([]model.Transaction, 0, s.config.batchSize())
+		return nil
+	}
+
+	for outcome := range results {
+		if workerCtx.Err() != nil {
+			continue
+		}
+
+		var rowErr *RowError
+		if options.lenient && errors.As(outcome.err, &rowErr) {
+			result.reject(rowErr)
+			continue
+		}
+		if outcome.err != nil {
+			p.fail(apperr.InvalidRow(outcome.err))
+			continue
+		}
+
+		result.add(outcome.transaction)
+		batch = append(batch, outcome.transaction)
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				p.fail(err)
+			}
+		}
+	}
+	<-readerDone
+
+	if p.err != nil {
+		return nil, "", p.err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", apperr.Wrap(apperr.ErrInterrupted, err)
+	}
+	if err := flush(); err != nil {
+		return nil, "", err
+	}
+
+	return result, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,148 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"stori-challenge/internal/model"
+	"testing"
+	"time"
+)
+
+// generatedFile produces a statement of n rows on the fly, so the benchmark never holds the file in memory.
+type generatedFile struct {
+	rows    int
+	next    int
+	pending []byte
+}
+
+func (g *generatedFile) Read(p []byte) (int, error) {
+	if len(g.pending) == 0 {
+		switch {
+		case g.next > g.rows:
+			return 0, io.EOF
+		case g.next == 0:
+			g.pending = []byte("Id,Date,Transaction\n")
+		default:
+			id := g.next - 1
+			g.pending = []byte(fmt.Sprintf("%d,%d/%d,%s\n", id, id%12+1, id%28+1, []string{"+1.25", "-0.75"}[id%2]))
+		}
+		g.next++
+	}
+
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+func (g *generatedFile) Close() error { return nil }
+
+// benchmarkStorage, benchmarkRepository and benchmarkEmail discard everything the processor hands them.
+type benchmarkStorage struct{ rows int }
+
+func (s benchmarkStorage) OpenFile(context.Context, string, string) (io.ReadCloser, error) {
+	return &generatedFile{rows: s.rows}, nil
+}
+
+func (s benchmarkStorage) WriteFile(context.Context, string, string, []byte, map[string]string) error {
+	return nil
+}
+
+func (s benchmarkStorage) Stat(context.Context, string, string) (*model.FileInfo, error) {
+	return &model.FileInfo{Metadata: map[string]string{PeriodMetadataKey: "2024-12"}}, nil
+}
+
+type benchmarkRepository struct{}
+
+func (benchmarkRepository) InsertTransactions(context.Context, []model.Transaction) error { return nil }
+func (benchmarkRepository) FindProcessedFile(context.Context, string, string, string) (*model.ProcessedFile, error) {
+	return nil, nil
+}
+func (benchmarkRepository) MarkProcessed(context.Context, *model.ProcessedFile) (bool, error) {
+	return true, nil
+}
+func (benchmarkRepository) ListTransactions(context.Context, string, TransactionFilter) ([]model.Transaction, error) {
+	return nil, nil
+}
+
+type benchmarkAccounts struct{}
+
+func (benchmarkAccounts) GetAccount(_ context.Context, id string) (*model.Account, error) {
+	return &model.Account{ID: id}, nil
+}
+
+type benchmarkUnitOfWork struct{}
+
+func (benchmarkUnitOfWork) Run(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
+
+type benchmarkEmail struct{}
+
+func (benchmarkEmail) SendEmail(context.Context, model.EmailParams) error { return nil }
+
+// peakHeap samples the live heap until stop is closed.
+func peakHeap(stop <-chan struct{}) <-chan uint64 {
+	peak := make(chan uint64, 1)
+	go func() {
+		var (
+			max   uint64
+			stats runtime.MemStats
+		)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > max {
+				max = stats.HeapAlloc
+			}
+			select {
+			case <-stop:
+				peak <- max
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return peak
+}
+
+// BenchmarkProcessCsvStreaming processes generated statements of growing size, the peak-heap-MB metric
+// stays flat as the number of rows grows because the file is streamed and inserted in batches.
+//
+//	go test ./internal/transaction -run '^$' -bench ProcessCsvStreaming -benchtime 1x
+func BenchmarkProcessCsvStreaming(b *testing.B) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, rows := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			service := NewService(benchmarkEmail{}, benchmarkStorage{rows: rows}, benchmarkRepository{},
+				benchmarkUnitOfWork{}, benchmarkAccounts{}, Config{})
+
+			var peak uint64
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				stop := make(chan struct{})
+				sampled := peakHeap(stop)
+
+				if _, err := service.ProcessCsv(context.Background(), "storicsv", "42/statement.csv"); err != nil {
+					b.Error(err)
+				}
+
+				close(stop)
+				if max := <-sampled; max > peak {
+					peak = max
+				}
+			}
+
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+			b.ReportMetric(float64(rows*b.N)/time.Since(start).Seconds(), "rows/s")
+		})
+	}
+}
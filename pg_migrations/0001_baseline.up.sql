@@ -0,0 +1,10 @@
+-- Schema of the former setup.sql, databases created with it already have this table and adopt the
+-- following migrations from here.
+create table if not exists transactions
+(
+    id         integer                 not null,
+    amount     float8                  not null,
+    date       varchar                 not null,
+    created_at timestamp default now() not null,
+    primary key (id, created_at)
+);
@@ -0,0 +1,2 @@
+alter table transactions drop column if exists account_id;
+drop table if exists accounts;
@@ -0,0 +1,22 @@
+create table if not exists accounts
+(
+    id       varchar                   not null,
+    name     varchar                   not null,
+    email    varchar                   not null,
+    locale   varchar default 'en'      not null,
+    currency varchar(3) default 'USD'  not null,
+    primary key (id)
+);
+
+alter table transactions add column if not exists account_id varchar references accounts (id);
+
+-- the transactions stored before accounts existed belong to the single recipient of the former
+-- lambda, they are kept under the "legacy" account until they are moved to the right one
+insert into accounts (id, name, email)
+select 'legacy', 'Legacy', 'legacy@invalid'
+where exists (select 1 from transactions where account_id is null)
+on conflict (id) do nothing;
+
+update transactions set account_id = 'legacy' where account_id is null;
+
+alter table transactions alter column account_id set not null;
@@ -0,0 +1 @@
+alter table transactions alter column amount type float8 using amount::float8;
@@ -0,0 +1,2 @@
+-- float8 values are converted with their 15 significant digits, e.g. -10.3 stays -10.3
+alter table transactions alter column amount type numeric using amount::numeric;
@@ -0,0 +1 @@
+alter table transactions alter column date type varchar using to_char(date, 'YYYY-MM-DD');
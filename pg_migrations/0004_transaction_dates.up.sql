@@ -0,0 +1,9 @@
+-- the former lambda parsed M/D dates without a year, they were stored as year 0000 timestamps
+-- (0000-07-15 00:00:00+00:00:00), the year is taken from the time the row was stored
+alter table transactions alter column date type date using
+    case
+        when date like '0000-%' then make_date(extract(year from created_at)::integer,
+                                               substr(date, 6, 2)::integer,
+                                               substr(date, 9, 2)::integer)
+        else left(date, 10)::date
+    end;
@@ -0,0 +1,3 @@
+alter table transactions
+    drop column if exists description,
+    drop column if exists merchant;
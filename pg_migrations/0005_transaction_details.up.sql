@@ -0,0 +1,3 @@
+alter table transactions
+    add column if not exists description varchar,
+    add column if not exists merchant    varchar;
@@ -0,0 +1,3 @@
+alter table transactions
+    drop constraint transactions_pkey,
+    add primary key (id, created_at);
@@ -0,0 +1,10 @@
+-- every run of the former lambda inserted the rows again, the last stored copy of each one is kept
+delete from transactions stale
+using transactions latest
+where stale.account_id = latest.account_id
+  and stale.id = latest.id
+  and stale.created_at < latest.created_at;
+
+alter table transactions
+    drop constraint transactions_pkey,
+    add primary key (account_id, id);
@@ -0,0 +1,13 @@
+create table if not exists processed_files
+(
+    checksum     varchar                   not null,
+    etag         varchar,
+    bucket       varchar                   not null,
+    key          varchar                   not null,
+    account_id   varchar                   not null references accounts (id),
+    summary      jsonb,
+    processed_at timestamptz default now() not null,
+    primary key (checksum)
+);
+
+create index if not exists processed_files_etag_idx on processed_files (etag);
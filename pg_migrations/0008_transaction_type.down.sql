@@ -0,0 +1 @@
+alter table transactions drop column if exists type;
@@ -0,0 +1,7 @@
+alter table transactions add column if not exists type varchar;
+
+update transactions set type = case when amount < 0 then 'debit' else 'credit' end where type is null;
+
+alter table transactions
+    alter column type set not null,
+    add constraint transactions_type_check check (type in ('debit', 'credit'));
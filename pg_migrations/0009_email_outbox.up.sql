@@ -0,0 +1,16 @@
+-- Emails are queued in the transaction of the file they report on and sent by the dispatcher.
+create table if not exists email_outbox
+(
+    id              bigserial                 not null,
+    email           jsonb                     not null,
+    status          varchar default 'pending' not null,
+    attempts        integer default 0         not null,
+    next_attempt_at timestamptz default now() not null,
+    last_error      varchar,
+    created_at      timestamptz default now() not null,
+    sent_at         timestamptz,
+    primary key (id),
+    constraint email_outbox_status_check check (status in ('pending', 'sent', 'dead'))
+);
+
+create index if not exists email_outbox_due_idx on email_outbox (next_attempt_at) where status = 'pending';
@@ -0,0 +1,12 @@
+drop index if exists processed_files_etag_idx;
+create index if not exists processed_files_etag_idx on processed_files (etag);
+
+-- a checksum uploaded for several accounts only keeps the first of its statements
+delete from processed_files later
+using processed_files earlier
+where later.checksum = earlier.checksum
+  and (later.processed_at, later.account_id) > (earlier.processed_at, earlier.account_id);
+
+alter table processed_files
+    drop constraint processed_files_pkey,
+    add primary key (checksum);
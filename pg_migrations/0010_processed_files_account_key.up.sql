@@ -0,0 +1,7 @@
+-- the same content uploaded for two accounts is two statements, files are only deduplicated per account
+alter table processed_files
+    drop constraint processed_files_pkey,
+    add primary key (account_id, checksum);
+
+drop index if exists processed_files_etag_idx;
+create index if not exists processed_files_etag_idx on processed_files (account_id, etag);
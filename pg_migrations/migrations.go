@@ -0,0 +1,8 @@
+// Package pgmigrations embeds the versioned schema of the database. Every change is a pair of files
+// NNNN_name.up.sql and NNNN_name.down.sql, applied in order by db.Migrator.
+package pgmigrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -1,15 +0,0 @@
-CREATE DATABASE postgres;
-
-CREATE USER postgres WITH PASSWORD 'admin';
-
-create table transactions
-(
-    id         integer                 not null,
-    amount     float8                 not null,
-    date       varchar                 not null,
-    created_at timestamp default now() not null,
-    primary key (id, created_at)
-);
-
-alter table transactions
-    owner to postgres;
\ No newline at end of file